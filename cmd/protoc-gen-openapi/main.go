@@ -0,0 +1,30 @@
+// Command protoc-gen-openapi is a protoc plugin writing the OpenAPI 3
+// document of the HTTP bindings of the files it is given to openapi.json,
+// for the server to embed. It is run by make proto.
+package main
+
+import (
+	"github.com/ariefro/simple-to-do-service/pkg/openapi"
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		for _, f := range gen.Files {
+			if !f.Generate {
+				continue
+			}
+
+			b, err := openapi.Generate(f.Desc)
+			if err != nil {
+				return err
+			}
+
+			if _, err := gen.NewGeneratedFile("openapi.json", "").Write(b); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	// callers' time zones have to load even without a system zoneinfo
+	_ "time/tzdata"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/audit"
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/ariefro/simple-to-do-service/pkg/gateway"
+	"github.com/ariefro/simple-to-do-service/pkg/health"
+	"github.com/ariefro/simple-to-do-service/pkg/logging"
+	"github.com/ariefro/simple-to-do-service/pkg/metrics"
+	"github.com/ariefro/simple-to-do-service/pkg/notify"
+	"github.com/ariefro/simple-to-do-service/pkg/openapi"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/ariefro/simple-to-do-service/pkg/tracing"
+	_ "github.com/go-sql-driver/mysql"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+)
+
+func main() {
+	var (
+		addr              = flag.String("addr", ":9090", "gRPC listen address")
+		httpAddr          = flag.String("http-addr", ":8080", "HTTP listen address of the JSON gateway, its OpenAPI document at /openapi.json and docs at /docs; disabled when empty")
+		dsn               = flag.String("dsn", "root:secret@tcp(localhost:3306)/todo?parseTime=true", "MySQL data source name")
+		blobDir           = flag.String("blob-dir", "", "directory for attachment blobs; attachments are disabled when empty")
+		maxAttachmentSize = flag.Int64("max-attachment-size", service.DefaultMaxAttachmentSize, "maximum attachment size in bytes")
+		trashRetention    = flag.Int("trash-retention-days", 30, "days a todo stays in the trash before it is purged; 0 disables the purge")
+		purgeInterval     = flag.Duration("purge-interval", time.Hour, "how often the trash, expired idempotency keys and undo tokens and old events are purged")
+		notifyInterval    = flag.Duration("notify-interval", 30*time.Second, "how often due notifications are delivered")
+		eventRetention    = flag.Duration("event-retention", 7*24*time.Hour, "how long changes are kept for Watch streams to resume from")
+		idempotencyWindow = flag.Duration("idempotency-window", service.DefaultIdempotencyWindow, "how long Create remembers an idempotency key")
+		auditAdmins       = flag.String("audit-admins", "", "comma-separated principals allowed to read the audit log, as set in x-user-id by the authenticating proxy")
+		logFormat         = flag.String("log-format", "text", "log format, text or json")
+		logLevel          = flag.String("log-level", "info", "lowest level logged: debug, info, warn or error")
+		logDescriptions   = flag.Bool("log-descriptions", false, "log todo descriptions instead of redacting them")
+		undoWindow        = flag.Duration("undo-window", service.DefaultUndoWindow, "how long the undo token returned by a mutation stays valid")
+		traceExporter     = flag.String("trace-exporter", "", "where spans are exported: otlp, stdout, or nowhere when empty")
+		otlpEndpoint      = flag.String("otlp-endpoint", "localhost:4317", "OTLP/gRPC collector address spans are exported to")
+		healthInterval    = flag.Duration("health-interval", 10*time.Second, "how often the database is pinged to report the health of the server")
+		drainDelay        = flag.Duration("drain-delay", 0, "how long the server keeps serving while reporting itself not serving before it stops")
+		enableReflection  = flag.Bool("reflection", false, "enable gRPC server reflection")
+		metricsAddr       = flag.String("metrics-addr", ":9091", "HTTP listen address serving Prometheus metrics on /metrics; metrics are disabled when empty")
+	)
+	flag.Parse()
+
+	logger, err := newLogger(*logFormat, *logLevel)
+	if err != nil {
+		log.Fatalf("invalid logging flags: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, *traceExporter, *otlpEndpoint)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("failed to flush spans: %v", err)
+		}
+	}()
+
+	db, err := tracing.OpenDB("mysql", *dsn)
+	if err != nil {
+		log.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		log.Fatalf("failed to connect to database: %v", err)
+	}
+
+	opts := []service.Option{service.WithIdempotencyWindow(*idempotencyWindow), service.WithUndoWindow(*undoWindow)}
+	var blobs blob.Store
+	if *blobDir != "" {
+		local, err := blob.NewLocalStore(*blobDir)
+		if err != nil {
+			log.Fatalf("failed to open blob store: %v", err)
+		}
+		blobs = local
+		opts = append(opts, service.WithBlobStore(blobs, *maxAttachmentSize))
+	}
+
+	if *trashRetention > 0 {
+		retention := time.Duration(*trashRetention) * 24 * time.Hour
+		go service.NewTrashPurger(db, blobs, retention, *purgeInterval).Run(ctx, func(err error) {
+			log.Printf("failed to purge trash: %v", err)
+		})
+	}
+
+	go func() {
+		ticker := time.NewTicker(*purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := service.PurgeIdempotencyKeys(ctx, db, time.Now()); err != nil {
+					log.Printf("failed to purge idempotency keys: %v", err)
+				}
+				if _, err := service.PurgeUndoEntries(ctx, db, time.Now()); err != nil {
+					log.Printf("failed to purge undo tokens: %v", err)
+				}
+				if _, err := event.Purge(ctx, db, time.Now().Add(-*eventRetention)); err != nil {
+					log.Printf("failed to purge events: %v", err)
+				}
+			}
+		}
+	}()
+
+	// until a real delivery channel is configured notifications are logged
+	sender := notify.SenderFunc(func(ctx context.Context, n notify.Notification) error {
+		log.Printf("notify %s (%s, todo %d): %s", n.Recipient, n.Kind, n.ToDoID, n.Message)
+		return nil
+	})
+	dispatcher := notify.NewDispatcher(db, sender, *notifyInterval)
+
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+	if *metricsAddr != "" {
+		m := metrics.New(db)
+		dispatcher.SetObserver(m)
+		unary = append(unary, m.UnaryServerInterceptor())
+		stream = append(stream, m.StreamServerInterceptor())
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		metricsServer := &http.Server{Addr: *metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			<-ctx.Done()
+			metricsServer.Close()
+		}()
+		go func() {
+			log.Printf("serving metrics on %s", *metricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
+	go dispatcher.Run(ctx)
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", *addr, err)
+	}
+
+	auditLog := audit.NewLog(db, func(err error) {
+		log.Printf("audit: %v", err)
+	})
+	go auditLog.Run()
+
+	var logOpts []logging.Option
+	if *logDescriptions {
+		logOpts = append(logOpts, logging.WithRedactedFields())
+	}
+	requestLog := logging.NewInterceptor(logger, logOpts...)
+
+	unary = append(unary, health.SkipUnary(requestLog.UnaryServerInterceptor()), health.SkipUnary(auditLog.UnaryServerInterceptor()))
+	stream = append(stream, health.SkipStream(requestLog.StreamServerInterceptor()), health.SkipStream(auditLog.StreamServerInterceptor()))
+
+	server := grpc.NewServer(
+		tracing.ServerOption(),
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+	todo.RegisterToDoServiceServer(server, service.NewTodoServiceServer(db, opts...))
+	todo.RegisterAuditServiceServer(server, service.NewAuditServiceServer(db, splitList(*auditAdmins)))
+
+	var services []string
+	for name := range server.GetServiceInfo() {
+		services = append(services, name)
+	}
+	checker := health.NewChecker(db, *healthInterval, services...)
+	checker.Register(server)
+	go checker.Run(ctx, func(err error) {
+		log.Printf("health check failed: %v", err)
+	})
+
+	if *enableReflection {
+		reflection.Register(server)
+	}
+
+	var gatewayServer *http.Server
+	if *httpAddr != "" {
+		// the gateway calls the server like any client, through its
+		// interceptors, over loopback so that the audit log takes the client
+		// address it forwards
+		target := lis.Addr().(*net.TCPAddr)
+		if target.IP.IsUnspecified() {
+			target = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: target.Port}
+		}
+		conn, err := grpc.NewClient("passthrough:///"+target.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			log.Fatalf("failed to connect the gateway: %v", err)
+		}
+		defer conn.Close()
+
+		h, err := gateway.NewHandler(ctx, conn)
+		if err != nil {
+			log.Fatalf("failed to create the gateway: %v", err)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/", h)
+		mux.Handle("/openapi.json", openapi.SpecHandler())
+		mux.Handle("/docs", openapi.UIHandler())
+		gatewayServer = &http.Server{Addr: *httpAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			log.Printf("serving HTTP on %s", *httpAddr)
+			if err := gatewayServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("failed to serve HTTP: %v", err)
+			}
+		}()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		<-ctx.Done()
+		checker.Shutdown()
+		time.Sleep(*drainDelay)
+
+		if gatewayServer != nil {
+			// the gateway drains first, as its calls in flight need the gRPC server
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := gatewayServer.Shutdown(shutdownCtx); err != nil {
+				log.Printf("failed to shut down HTTP: %v", err)
+			}
+		}
+		server.GracefulStop()
+
+		// every call has returned, so its entry is queued
+		auditLog.Close()
+	}()
+
+	log.Printf("serving gRPC on %s", lis.Addr())
+	if err := server.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+	<-stopped
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// newLogger creates the logger writing to stderr in the given format from
+// the given level up.
+func newLogger(format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
@@ -0,0 +1,7 @@
+CREATE TABLE `todo` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `title` varchar(255) NOT NULL,
+  `description` text NOT NULL,
+  `reminder` datetime NOT NULL,
+  PRIMARY KEY (`id`)
+);
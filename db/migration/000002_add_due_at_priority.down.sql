@@ -0,0 +1,8 @@
+DROP INDEX `todo_priority_idx` ON `todo`;
+DROP INDEX `todo_due_at_idx` ON `todo`;
+
+ALTER TABLE `todo`
+  DROP COLUMN `reminder_offset_seconds`,
+  DROP COLUMN `priority`,
+  DROP COLUMN `due_date_only`,
+  DROP COLUMN `due_at`;
@@ -0,0 +1,8 @@
+ALTER TABLE `todo`
+  ADD COLUMN `due_at` datetime NULL,
+  ADD COLUMN `due_date_only` boolean NOT NULL DEFAULT FALSE,
+  ADD COLUMN `priority` tinyint NOT NULL DEFAULT 0,
+  ADD COLUMN `reminder_offset_seconds` bigint NULL;
+
+CREATE INDEX `todo_due_at_idx` ON `todo` (`due_at`);
+CREATE INDEX `todo_priority_idx` ON `todo` (`priority`);
@@ -0,0 +1,5 @@
+DROP INDEX `todo_list_rank_idx` ON `todo`;
+
+ALTER TABLE `todo`
+  DROP COLUMN `rank_key`,
+  DROP COLUMN `list_id`;
@@ -0,0 +1,9 @@
+-- rank_key must compare byte by byte, so it uses a binary collation
+ALTER TABLE `todo`
+  ADD COLUMN `list_id` bigint NOT NULL DEFAULT 0,
+  ADD COLUMN `rank_key` varchar(255) CHARACTER SET ascii COLLATE ascii_bin NOT NULL DEFAULT '';
+
+-- give existing todos a valid key in id order; keys never end in '0'
+UPDATE `todo` SET `rank_key` = CONCAT(LPAD(CONV(`id`, 10, 36), 8, '0'), 'V');
+
+CREATE INDEX `todo_list_rank_idx` ON `todo` (`list_id`, `rank_key`);
@@ -0,0 +1,10 @@
+CREATE TABLE `checklist_item` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `todo_id` bigint NOT NULL,
+  `text` varchar(500) NOT NULL,
+  `checked` boolean NOT NULL DEFAULT FALSE,
+  `position` int NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  KEY `checklist_item_todo_id_idx` (`todo_id`, `position`),
+  CONSTRAINT `checklist_item_todo_id_fk` FOREIGN KEY (`todo_id`) REFERENCES `todo` (`id`) ON DELETE CASCADE
+);
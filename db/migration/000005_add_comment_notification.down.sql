@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `notification`;
+DROP TABLE IF EXISTS `comment_edit`;
+DROP TABLE IF EXISTS `todo_comment`;
@@ -0,0 +1,35 @@
+CREATE TABLE `todo_comment` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `todo_id` bigint NOT NULL,
+  `author` varchar(255) NOT NULL,
+  `body` text NOT NULL,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `todo_comment_todo_id_idx` (`todo_id`, `created_at`),
+  CONSTRAINT `todo_comment_todo_id_fk` FOREIGN KEY (`todo_id`) REFERENCES `todo` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE `comment_edit` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `comment_id` bigint NOT NULL,
+  `body` text NOT NULL,
+  `edited_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `comment_edit_comment_id_idx` (`comment_id`),
+  CONSTRAINT `comment_edit_comment_id_fk` FOREIGN KEY (`comment_id`) REFERENCES `todo_comment` (`id`) ON DELETE CASCADE
+);
+
+-- outbox of the notification pipeline, drained by notify.Dispatcher
+CREATE TABLE `notification` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `recipient` varchar(255) NOT NULL,
+  `kind` varchar(32) NOT NULL,
+  `todo_id` bigint NOT NULL,
+  `message` text NOT NULL,
+  `deliver_at` datetime NOT NULL,
+  `delivered_at` datetime NULL,
+  PRIMARY KEY (`id`),
+  KEY `notification_due_idx` (`delivered_at`, `deliver_at`),
+  KEY `notification_todo_id_idx` (`todo_id`, `kind`)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE `attachment` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `todo_id` bigint NOT NULL,
+  `filename` varchar(255) NOT NULL,
+  `content_type` varchar(255) NOT NULL,
+  `size` bigint NOT NULL,
+  `sha256` char(64) NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `attachment_todo_id_idx` (`todo_id`),
+  KEY `attachment_sha256_idx` (`sha256`),
+  CONSTRAINT `attachment_todo_id_fk` FOREIGN KEY (`todo_id`) REFERENCES `todo` (`id`) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+DROP INDEX `todo_deleted_at_idx` ON `todo`;
+
+ALTER TABLE `todo` DROP COLUMN `deleted_at`;
@@ -0,0 +1,3 @@
+ALTER TABLE `todo` ADD COLUMN `deleted_at` datetime NULL;
+
+CREATE INDEX `todo_deleted_at_idx` ON `todo` (`deleted_at`);
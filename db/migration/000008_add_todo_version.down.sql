@@ -0,0 +1 @@
+ALTER TABLE `todo` DROP COLUMN `version`;
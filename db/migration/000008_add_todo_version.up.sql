@@ -0,0 +1 @@
+ALTER TABLE `todo` ADD COLUMN `version` bigint NOT NULL DEFAULT 1;
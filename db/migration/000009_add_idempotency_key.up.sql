@@ -0,0 +1,10 @@
+CREATE TABLE `idempotency_key` (
+  `principal` varchar(255) NOT NULL,
+  `key` varchar(255) NOT NULL,
+  `request_hash` char(64) NOT NULL,
+  `response` blob NOT NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`principal`, `key`)
+);
+
+CREATE INDEX `idempotency_key_expires_at_idx` ON `idempotency_key` (`expires_at`);
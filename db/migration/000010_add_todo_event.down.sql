@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `todo_event_sequence`;
+DROP TABLE IF EXISTS `todo_event`;
@@ -0,0 +1,25 @@
+-- change feed read by Watch, see package event
+CREATE TABLE `todo_event` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  -- given once the change is committed, NULL until then
+  `seq` bigint DEFAULT NULL,
+  `todo_id` bigint NOT NULL,
+  `list_id` bigint NOT NULL,
+  `kind` varchar(32) NOT NULL,
+  `created_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `todo_event_seq_idx` (`seq`),
+  KEY `todo_event_list_id_idx` (`list_id`, `seq`),
+  KEY `todo_event_created_at_idx` (`created_at`)
+);
+
+-- the single row handing out the sequences of the feed; last_seq is the
+-- last one given to an event and purged_seq the last one purged
+CREATE TABLE `todo_event_sequence` (
+  `id` tinyint NOT NULL,
+  `last_seq` bigint NOT NULL,
+  `purged_seq` bigint NOT NULL,
+  PRIMARY KEY (`id`)
+);
+
+INSERT INTO `todo_event_sequence`(`id`, `last_seq`, `purged_seq`) VALUES (1, 0, 0);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `sync_client_todo`;
+DROP TABLE IF EXISTS `todo_field_clock`;
@@ -0,0 +1,18 @@
+-- when each field of a todo was last changed, for conflict resolution in Sync
+CREATE TABLE `todo_field_clock` (
+  `todo_id` bigint NOT NULL,
+  `field` varchar(32) NOT NULL,
+  `updated_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`todo_id`, `field`),
+  CONSTRAINT `todo_field_clock_todo_id_fk` FOREIGN KEY (`todo_id`) REFERENCES `todo` (`id`) ON DELETE CASCADE
+);
+
+-- the todos created by Sync, by the client id they were sent with, so that a
+-- retried sync does not create them again; kept when the todo is purged, so
+-- a retry gets a tombstone
+CREATE TABLE `sync_client_todo` (
+  `principal` varchar(255) NOT NULL,
+  `client_id` varchar(255) NOT NULL,
+  `todo_id` bigint NOT NULL,
+  PRIMARY KEY (`principal`, `client_id`)
+);
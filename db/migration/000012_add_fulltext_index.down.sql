@@ -0,0 +1,2 @@
+DROP INDEX `todo_comment_fulltext_idx` ON `todo_comment`;
+DROP INDEX `todo_fulltext_idx` ON `todo`;
@@ -0,0 +1,3 @@
+-- searched in boolean mode by Search
+CREATE FULLTEXT INDEX `todo_fulltext_idx` ON `todo` (`title`, `description`);
+CREATE FULLTEXT INDEX `todo_comment_fulltext_idx` ON `todo_comment` (`body`);
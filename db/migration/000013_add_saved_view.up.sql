@@ -0,0 +1,13 @@
+CREATE TABLE `saved_view` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `owner` varchar(255) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `query` text NOT NULL,
+  `sort_by` int NOT NULL DEFAULT 0,
+  `descending` boolean NOT NULL DEFAULT FALSE,
+  `group_by` int NOT NULL DEFAULT 0,
+  `created_at` datetime NOT NULL,
+  `updated_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `saved_view_owner_name_idx` (`owner`, `name`)
+);
@@ -0,0 +1,30 @@
+-- snapshot holds the todo after the change in protobuf JSON form; there is
+-- no foreign key on todo_id, so a todo's history outlives its purge
+CREATE TABLE `todo_revision` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `todo_id` bigint NOT NULL,
+  `actor` varchar(255) NOT NULL,
+  `kind` varchar(16) NOT NULL,
+  `snapshot` json NOT NULL,
+  `changes` json NOT NULL,
+  `created_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `todo_revision_todo_id_idx` (`todo_id`, `id`)
+);
+
+-- existing todos start their history from their current state
+INSERT INTO `todo_revision`(`todo_id`, `actor`, `kind`, `snapshot`, `changes`, `created_at`)
+SELECT `id`, '', 'created',
+  JSON_OBJECT(
+    'title', `title`,
+    'description', `description`,
+    'reminder', DATE_FORMAT(`reminder`, '%Y-%m-%dT%H:%i:%sZ'),
+    'due_at', DATE_FORMAT(`due_at`, '%Y-%m-%dT%H:%i:%sZ'),
+    'due_date_only', IF(`due_date_only`, CAST('true' AS JSON), CAST('false' AS JSON)),
+    'priority', ELT(`priority` + 1, 'PRIORITY_UNSPECIFIED', 'PRIORITY_P1', 'PRIORITY_P2', 'PRIORITY_P3', 'PRIORITY_P4'),
+    'reminder_before_due', CONCAT(`reminder_offset_seconds`, 's'),
+    'list_id', `list_id`,
+    'deleted_at', DATE_FORMAT(`deleted_at`, '%Y-%m-%dT%H:%i:%sZ')
+  ),
+  JSON_ARRAY(), NOW(6)
+FROM `todo`;
@@ -0,0 +1,13 @@
+-- one row per todo changed by a call that can be undone; snapshot holds the
+-- todo before the call and is NULL for a todo the call created
+CREATE TABLE `undo_entry` (
+  `token` char(32) NOT NULL,
+  `todo_id` bigint NOT NULL,
+  `principal` varchar(255) NOT NULL,
+  `version` bigint NOT NULL,
+  `snapshot` json NULL,
+  `expires_at` datetime NOT NULL,
+  PRIMARY KEY (`token`, `todo_id`),
+  KEY `undo_entry_expires_at_idx` (`expires_at`),
+  CONSTRAINT `undo_entry_todo_id_fk` FOREIGN KEY (`todo_id`) REFERENCES `todo` (`id`) ON DELETE CASCADE
+);
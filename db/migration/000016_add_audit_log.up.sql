@@ -0,0 +1,28 @@
+-- one row per API call, see package audit
+CREATE TABLE `audit_log` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(6) NOT NULL,
+  `principal` varchar(255) NOT NULL,
+  `method` varchar(255) NOT NULL,
+  `resource_ids` json NOT NULL,
+  `code` varchar(32) NOT NULL,
+  `client_ip` varchar(64) NOT NULL,
+  `latency_us` bigint NOT NULL,
+  `prev_hash` char(64) NOT NULL,
+  `hash` char(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `audit_log_principal_idx` (`principal`, `id`),
+  KEY `audit_log_created_at_idx` (`created_at`)
+);
+
+-- the chain starts from a single genesis entry, which every instance appends
+-- after, see package audit
+INSERT INTO `audit_log`(`created_at`, `principal`, `method`, `resource_ids`, `code`, `client_ip`, `latency_us`, `prev_hash`, `hash`)
+VALUES (NOW(6), '', '', '[]', '', '', 0, '', REPEAT('0', 64));
+
+-- the log is append-only
+CREATE TRIGGER `audit_log_no_update` BEFORE UPDATE ON `audit_log`
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
+
+CREATE TRIGGER `audit_log_no_delete` BEFORE DELETE ON `audit_log`
+FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_log is append-only';
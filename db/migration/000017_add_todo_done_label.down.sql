@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `todo_label`;
+ALTER TABLE `todo` DROP COLUMN `done`;
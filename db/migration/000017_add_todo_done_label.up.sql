@@ -0,0 +1,10 @@
+ALTER TABLE `todo` ADD COLUMN `done` boolean NOT NULL DEFAULT FALSE;
+
+-- labels are stored lower case, so label: terms match them in any case
+CREATE TABLE `todo_label` (
+  `todo_id` bigint NOT NULL,
+  `label` varchar(64) NOT NULL,
+  PRIMARY KEY (`todo_id`, `label`),
+  KEY `todo_label_label_idx` (`label`),
+  CONSTRAINT `todo_label_todo_id_fk` FOREIGN KEY (`todo_id`) REFERENCES `todo` (`id`) ON DELETE CASCADE
+);
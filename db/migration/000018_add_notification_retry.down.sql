@@ -0,0 +1,3 @@
+ALTER TABLE `notification`
+  DROP COLUMN `next_attempt_at`,
+  DROP COLUMN `attempts`;
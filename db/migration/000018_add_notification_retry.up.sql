@@ -0,0 +1,6 @@
+-- a notification whose delivery failed is retried at next_attempt_at, with a
+-- delay growing with attempts, so it does not hold up the ones due after it;
+-- NULL until the first attempt fails
+ALTER TABLE `notification`
+  ADD COLUMN `attempts` int NOT NULL DEFAULT 0,
+  ADD COLUMN `next_attempt_at` datetime NULL;
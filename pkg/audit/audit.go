@@ -0,0 +1,328 @@
+// Package audit records every call to the API in the audit_log table. The
+// table is append-only and its entries form a hash chain: each entry holds
+// the hash of the one before it and a hash over both, so an entry edited or
+// removed behind the service's back is detected by Verify.
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is one call to the API. Principal is the caller's x-user-id as
+// received, see util.PrincipalMetadataKey.
+type Entry struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	Principal   string    `json:"principal"`
+	Method      string    `json:"method"`
+	ResourceIDs []int64   `json:"resource_ids"`
+	Code        string    `json:"code"`
+	ClientIP    string    `json:"client_ip"`
+	LatencyUS   int64     `json:"latency_us"`
+	PrevHash    string    `json:"prev_hash"`
+	Hash        string    `json:"hash"`
+}
+
+// hash computes the hash of an entry from prev_hash and the fields recorded
+// for the call. The id is left out as it is only known once inserted.
+func (e Entry) hash() string {
+	e.ID = 0
+	e.Hash = ""
+
+	// marshalling a struct of times, strings and numbers cannot fail
+	b, _ := json.Marshal(e)
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// genesisHash is the hash of the entry the migration seeds the chain with,
+// which every instance then appends after.
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+const (
+	// queueSize is how many recorded calls can wait for the writer before
+	// calls wait for it in turn
+	queueSize = 1024
+	// batchSize caps the entries appended in one transaction
+	batchSize = 100
+	// a batch that fails to be appended is retried after minRetryDelay,
+	// doubling up to maxRetryDelay
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 30 * time.Second
+)
+
+// Log appends entries to the audit log. Calls are recorded on a queue
+// drained by Run, so they do not wait for the head of the chain.
+type Log struct {
+	db      *sql.DB
+	onError func(error)
+
+	queue chan *Entry
+	done  chan struct{}
+}
+
+// NewLog creates a log writing to db. onError, when not nil, is called
+// whenever a batch of entries fails to be appended, before it is retried.
+func NewLog(db *sql.DB, onError func(error)) *Log {
+	return &Log{
+		db:      db,
+		onError: onError,
+		queue:   make(chan *Entry, queueSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Run appends the recorded calls, a batch at a time, until Close is called
+// and the queue is drained. A batch that cannot be appended is retried until
+// it is: calls are never dropped, so while the database is unavailable the
+// queue fills up and calls wait for it in turn.
+func (l *Log) Run() {
+	defer close(l.done)
+
+	for e := range l.queue {
+		batch := []*Entry{e}
+	fill:
+		for len(batch) < batchSize {
+			select {
+			case e, ok := <-l.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+
+		l.appendRetrying(batch)
+	}
+}
+
+// appendRetrying appends a batch, retrying with a growing delay until it is
+// appended. Append sets the hashes anew on every attempt, so a retried batch
+// is linked to the head of the chain at the time.
+func (l *Log) appendRetrying(batch []*Entry) {
+	delay := minRetryDelay
+	for {
+		err := l.Append(context.Background(), batch...)
+		if err == nil {
+			return
+		}
+
+		if l.onError != nil {
+			l.onError(fmt.Errorf("failed to record %d calls, retrying in %v: %w", len(batch), delay, err))
+		}
+		time.Sleep(delay)
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// Close stops the log once the calls recorded so far are appended, which
+// waits for the database when it is unavailable. It must
+// only be called when no call is in flight anymore, and waits for Run to
+// return.
+func (l *Log) Close() {
+	close(l.queue)
+	<-l.done
+}
+
+// Append links entries to the head of the chain, in order, and inserts them
+// in a single transaction, setting their ids and hashes.
+func (l *Log) Append(ctx context.Context, entries ...*Entry) error {
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// locking the head serializes appends across instances
+	var head string
+	if err := tx.QueryRowContext(ctx, "SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1 FOR UPDATE").Scan(&head); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("the audit log has no genesis entry")
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		// times are stored with microsecond precision and hashed as stored
+		e.Time = e.Time.UTC().Truncate(time.Microsecond)
+		if e.ResourceIDs == nil {
+			e.ResourceIDs = []int64{}
+		}
+
+		ids, err := json.Marshal(e.ResourceIDs)
+		if err != nil {
+			return err
+		}
+
+		e.PrevHash = head
+		e.Hash = e.hash()
+
+		res, err := tx.ExecContext(ctx, "INSERT INTO audit_log(`created_at`, `principal`, `method`, `resource_ids`, `code`, `client_ip`, `latency_us`, `prev_hash`, `hash`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			e.Time, e.Principal, e.Method, ids, e.Code, e.ClientIP, e.LatencyUS, e.PrevHash, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		if e.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		head = e.Hash
+	}
+
+	return tx.Commit()
+}
+
+// Filter selects entries of the log. Zero fields match every entry.
+type Filter struct {
+	Principal  string
+	Method     string
+	ResourceID int64
+	// Since is inclusive, Until exclusive.
+	Since time.Time
+	Until time.Time
+	// AfterID skips the entries up to and including this id.
+	AfterID int64
+	// Limit caps the entries returned.
+	Limit int
+}
+
+const entryColumns = "id, created_at, principal, method, resource_ids, code, client_ip, latency_us, prev_hash, hash"
+
+// Query returns the entries matching f, oldest first.
+func Query(ctx context.Context, db *sql.DB, f Filter) ([]Entry, error) {
+	var (
+		conds = []string{"id > ?"}
+		args  = []interface{}{f.AfterID}
+	)
+	if f.Principal != "" {
+		conds = append(conds, "principal = ?")
+		args = append(args, f.Principal)
+	}
+	if f.Method != "" {
+		conds = append(conds, "method = ?")
+		args = append(args, f.Method)
+	}
+	if f.ResourceID != 0 {
+		conds = append(conds, "JSON_CONTAINS(resource_ids, ?)")
+		args = append(args, strconv.FormatInt(f.ResourceID, 10))
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.Since)
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.Until)
+	}
+
+	query := "SELECT " + entryColumns + " FROM audit_log WHERE " + strings.Join(conds, " AND ") + " ORDER BY id"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var (
+			e   Entry
+			ids []byte
+		)
+		if err := rows.Scan(&e.ID, &e.Time, &e.Principal, &e.Method, &ids, &e.Code, &e.ClientIP, &e.LatencyUS, &e.PrevHash, &e.Hash); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(ids, &e.ResourceIDs); err != nil {
+			return nil, err
+		}
+		e.Time = e.Time.UTC()
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// pageSize is how many entries Export and Verify read at a time
+const pageSize = 500
+
+// Export writes the entries matching f to w as JSON lines, oldest first.
+// Each page of entries is written with a single Write. f.Limit is ignored.
+func Export(ctx context.Context, db *sql.DB, f Filter, w io.Writer) error {
+	f.Limit = pageSize
+	for {
+		entries, err := Query(ctx, db, f)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+
+		f.AfterID = entries[len(entries)-1].ID
+	}
+}
+
+// Verify walks the whole chain, from the genesis entry, and returns the
+// number of entries checked and the id of the first entry that does not
+// match its hash or does not follow the entry before it, 0 when the chain is
+// intact.
+func Verify(ctx context.Context, db *sql.DB) (int64, int64, error) {
+	var (
+		checked int64
+		prev    string
+		f       = Filter{Limit: pageSize}
+	)
+	for {
+		entries, err := Query(ctx, db, f)
+		if err != nil {
+			return checked, 0, err
+		}
+		if len(entries) == 0 {
+			return checked, 0, nil
+		}
+
+		for _, e := range entries {
+			checked++
+			want := e.hash()
+			if checked == 1 {
+				want = genesisHash
+			}
+			if e.PrevHash != prev || e.Hash != want {
+				return checked, e.ID, nil
+			}
+			prev = e.Hash
+		}
+
+		f.AfterID = entries[len(entries)-1].ID
+	}
+}
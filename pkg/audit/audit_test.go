@@ -0,0 +1,316 @@
+package audit_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	headQuery   = "SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1 FOR UPDATE"
+	insertQuery = "INSERT INTO audit_log(`created_at`, `principal`, `method`, `resource_ids`, `code`, `client_ip`, `latency_us`, `prev_hash`, `hash`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	selectQuery = "SELECT id, created_at, principal, method, resource_ids, code, client_ip, latency_us, prev_hash, hash FROM audit_log WHERE id > ? ORDER BY id LIMIT ?"
+)
+
+// genesisHash is the hash of the entry seeded by the migration
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+var entryColumns = []string{"id", "created_at", "principal", "method", "resource_ids", "code", "client_ip", "latency_us", "prev_hash", "hash"}
+
+func expectAppend(mock sqlmock.Sqlmock, head string, id int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(head))
+	mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), head, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(id, 1))
+	mock.ExpectCommit()
+}
+
+// appendEntries appends two entries after the genesis entry and returns the
+// three of them as stored.
+func appendEntries(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock) []audit.Entry {
+	l := audit.NewLog(db, nil)
+	genesis := audit.Entry{ID: 1, Time: time.Now().UTC().Truncate(time.Microsecond), ResourceIDs: []int64{}, Hash: genesisHash}
+
+	first := &audit.Entry{Time: time.Now(), Principal: "alice", Method: "/pb.ToDoService/Create", ResourceIDs: []int64{1}, Code: "OK"}
+	expectAppend(mock, genesisHash, 2)
+	assert.NoError(t, l.Append(context.Background(), first))
+
+	second := &audit.Entry{Time: time.Now(), Principal: "bob", Method: "/pb.ToDoService/Delete", ResourceIDs: []int64{1}, Code: "OK"}
+	expectAppend(mock, first.Hash, 3)
+	assert.NoError(t, l.Append(context.Background(), second))
+
+	return []audit.Entry{genesis, *first, *second}
+}
+
+func entryRows(entries []audit.Entry) *sqlmock.Rows {
+	rows := sqlmock.NewRows(entryColumns)
+	for _, e := range entries {
+		ids := "[1]"
+		if len(e.ResourceIDs) == 0 {
+			ids = "[]"
+		}
+		rows.AddRow(e.ID, e.Time, e.Principal, e.Method, ids, e.Code, e.ClientIP, e.LatencyUS, e.PrevHash, e.Hash)
+	}
+
+	return rows
+}
+
+func TestAppendChainsEntries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	entries := appendEntries(t, db, mock)
+
+	assert.Equal(t, int64(3), entries[2].ID)
+	assert.Equal(t, genesisHash, entries[1].PrevHash)
+	assert.Len(t, entries[1].Hash, 64)
+	assert.Equal(t, entries[1].Hash, entries[2].PrevHash)
+	assert.NotEqual(t, entries[1].Hash, entries[2].Hash)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendBatchInOneTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	first := &audit.Entry{Time: time.Now(), Principal: "alice", Method: "/pb.ToDoService/Create", Code: "OK"}
+	second := &audit.Entry{Time: time.Now(), Principal: "bob", Method: "/pb.ToDoService/Delete", Code: "OK"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(genesisHash))
+	mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(sqlmock.AnyArg(), "alice", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), genesisHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(sqlmock.AnyArg(), "bob", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	err = audit.NewLog(db, nil).Append(context.Background(), first, second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	assert.Equal(t, int64(3), second.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAppendWithoutGenesis(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// the chain is never started by an append, which two instances could
+	// both do on an empty table
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	mock.ExpectRollback()
+
+	err = audit.NewLog(db, nil).Append(context.Background(), &audit.Entry{Time: time.Now(), Method: "/pb.ToDoService/Create", Code: "OK"})
+
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerify(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	entries := appendEntries(t, db, mock)
+
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(0, 500).WillReturnRows(entryRows(entries))
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(3, 500).WillReturnRows(sqlmock.NewRows(entryColumns))
+
+	checked, broken, err := audit.Verify(context.Background(), db)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), checked)
+	assert.Equal(t, int64(0), broken)
+
+	// bob's call rewritten as alice's
+	entries[2].Principal = "alice"
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(0, 500).WillReturnRows(entryRows(entries))
+
+	_, broken, err = audit.Verify(context.Background(), db)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), broken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyDetectsRemovedEntry(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	entries := appendEntries(t, db, mock)
+
+	// alice's call removed
+	mock.ExpectQuery(regexp.QuoteMeta(selectQuery)).WithArgs(0, 500).WillReturnRows(entryRows([]audit.Entry{entries[0], entries[2]}))
+
+	_, broken, err := audit.Verify(context.Background(), db)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), broken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	l := audit.NewLog(db, nil)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "alice"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 51234}})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(genesisHash))
+	mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(sqlmock.AnyArg(), "alice", "/pb.ToDoService/BatchDelete", []byte("[3,5]"), "NotFound", "10.0.0.1", sqlmock.AnyArg(), genesisHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	req := &todo.BatchDeleteRequest{Items: []*todo.DeleteRequest{{Id: 3}, {Id: 5, Version: 2}, {Id: 3}}}
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/BatchDelete"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "todo not found")
+	}
+
+	_, err = l.UnaryServerInterceptor()(ctx, req, info, handler)
+
+	// the call returns before its entry is appended
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Error(t, mock.ExpectationsWereMet())
+
+	go l.Run()
+	l.Close()
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnaryServerInterceptorRecordsCreatedID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var recordErr error
+	l := audit.NewLog(db, func(err error) { recordErr = err })
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(genesisHash))
+	mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(sqlmock.AnyArg(), "", "/pb.ToDoService/Create", []byte("[9]"), "OK", "", sqlmock.AnyArg(), genesisHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &todo.CreateToDoResponse{Id: 9}, nil
+	}
+
+	_, err = l.UnaryServerInterceptor()(context.Background(), &todo.CreateToDoRequest{ToDo: &todo.ToDo{Title: "new"}}, info, handler)
+	go l.Run()
+	l.Close()
+
+	assert.NoError(t, err)
+	assert.NoError(t, recordErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunRetriesFailedBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	var recordErrs []error
+	l := audit.NewLog(db, func(err error) { recordErrs = append(recordErrs, err) })
+
+	// the batch is kept and appended once the database is back
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(genesisHash))
+	mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+		WithArgs(sqlmock.AnyArg(), "", "/pb.ToDoService/Delete", []byte("[4]"), "OK", "", sqlmock.AnyArg(), genesisHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Delete"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &todo.DeleteResponse{}, nil
+	}
+
+	_, err = l.UnaryServerInterceptor()(context.Background(), &todo.DeleteRequest{Id: 4}, info, handler)
+	go l.Run()
+	l.Close()
+
+	assert.NoError(t, err)
+	if assert.Len(t, recordErrs, 1) {
+		assert.Contains(t, recordErrs[0].Error(), "connection refused")
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnaryServerInterceptorRecordsForwardedClient(t *testing.T) {
+	tests := []struct {
+		name string
+		peer string
+		fwd  string
+		want string
+	}{
+		{"gateway", "127.0.0.1", "198.51.100.7", "198.51.100.7"},
+		{"gateway behind a client-set header", "::1", "203.0.113.9, 198.51.100.7", "198.51.100.7"},
+		{"direct client", "10.0.0.1", "198.51.100.7", "10.0.0.1"},
+		{"gateway without header", "127.0.0.1", "", "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			l := audit.NewLog(db, nil)
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(tt.peer), Port: 51234}})
+			if tt.fwd != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-forwarded-for", tt.fwd))
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta(headQuery)).WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(genesisHash))
+			mock.ExpectExec(regexp.QuoteMeta(insertQuery)).
+				WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), tt.want, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(2, 1))
+			mock.ExpectCommit()
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Read"}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			}
+
+			_, err = l.UnaryServerInterceptor()(ctx, &todo.ReadToDoRequest{Id: 1}, info, handler)
+			go l.Run()
+			l.Close()
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
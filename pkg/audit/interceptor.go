@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UnaryServerInterceptor records every unary call once it has been handled.
+func (l *Log) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		l.record(ctx, info.FullMethod, start, err, req, resp)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records every streaming call once it has ended.
+// The resource ids are taken from the first message received.
+func (l *Log) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		stream := &recordingStream{ServerStream: ss}
+		err := handler(srv, stream)
+		l.record(ss.Context(), info.FullMethod, start, err, stream.first, nil)
+
+		return err
+	}
+}
+
+// recordingStream keeps the first message received on a stream.
+type recordingStream struct {
+	grpc.ServerStream
+	first interface{}
+}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.first == nil {
+		s.first = m
+	}
+
+	return err
+}
+
+func (l *Log) record(ctx context.Context, method string, start time.Time, err error, req, resp interface{}) {
+	e := &Entry{
+		Time:        start,
+		Principal:   util.PrincipalFromContext(ctx),
+		Method:      method,
+		ResourceIDs: resourceIDs(req, resp),
+		Code:        status.Code(err).String(),
+		ClientIP:    clientIP(ctx),
+		LatencyUS:   time.Since(start).Microseconds(),
+	}
+
+	l.queue <- e
+}
+
+// resourceFields are the request fields holding the ids of the resources a
+// call acts on.
+var resourceFields = map[protoreflect.Name]bool{
+	"id":       true,
+	"ids":      true,
+	"to_do_id": true,
+}
+
+// resourceIDs collects the resource ids found anywhere in req and the id of
+// the resource created by the call, if any, in order of appearance.
+func resourceIDs(req, resp interface{}) []int64 {
+	var (
+		ids  = []int64{}
+		seen = map[int64]bool{}
+	)
+	add := func(id int64) {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if m, ok := req.(proto.Message); ok && m != nil {
+		collectIDs(m.ProtoReflect(), add)
+	}
+
+	// responses can hold any number of todos, only a created id is taken
+	if m, ok := resp.(proto.Message); ok && m != nil {
+		r := m.ProtoReflect()
+		if fd := r.Descriptor().Fields().ByName("id"); fd != nil && fd.Kind() == protoreflect.Int64Kind && !fd.IsList() {
+			add(r.Get(fd).Int())
+		}
+	}
+
+	return ids
+}
+
+func collectIDs(m protoreflect.Message, add func(int64)) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				collectIDs(v.List().Get(i).Message(), add)
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			collectIDs(v.Message(), add)
+		case fd.Kind() == protoreflect.Int64Kind && resourceFields[fd.Name()]:
+			if fd.IsList() {
+				for i := 0; i < v.List().Len(); i++ {
+					add(v.List().Get(i).Int())
+				}
+			} else {
+				add(v.Int())
+			}
+		}
+
+		return true
+	})
+}
+
+// forwardedForMetadataKey holds the addresses an HTTP call was forwarded
+// for, the last one added by the gateway from the address of its client.
+const forwardedForMetadataKey = "x-forwarded-for"
+
+// clientIP returns the address the call came from, without its port. The
+// gateway calls the server from loopback, so for those calls it is the
+// address of the HTTP client the gateway forwarded the call for. Any
+// address before it was sent by the client and is not trusted.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		if fwd := metadata.ValueFromIncomingContext(ctx, forwardedForMetadataKey); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if last := strings.TrimSpace(hops[len(hops)-1]); last != "" {
+				return last
+			}
+		}
+	}
+
+	return host
+}
@@ -0,0 +1,26 @@
+// Package blob stores attachment contents. Blobs are addressed by the
+// hex-encoded SHA-256 of their content, so identical uploads share a blob.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when a blob does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a content-addressed blob store.
+type Store interface {
+	// Put stores the content read from r under key. Storing a key that
+	// already exists is a no-op.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the blob stored under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Exists reports whether a blob is stored under key.
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
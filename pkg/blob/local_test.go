@@ -0,0 +1,57 @@
+package blob_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := keyOf("hello")
+
+	exists, err := store.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, store.Put(ctx, key, strings.NewReader("hello")))
+	// a second put of the same key keeps the first blob
+	require.NoError(t, store.Put(ctx, key, strings.NewReader("ignored")))
+
+	r, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+
+	require.NoError(t, store.Delete(ctx, key))
+	require.NoError(t, store.Delete(ctx, key))
+
+	_, err = store.Get(ctx, key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestLocalStoreRejectsInvalidKeys(t *testing.T) {
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "../etc/passwd", strings.Repeat("g", 64)} {
+		assert.Error(t, store.Put(context.Background(), key, strings.NewReader("x")), key)
+	}
+}
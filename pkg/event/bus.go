@@ -0,0 +1,47 @@
+package event
+
+import "sync"
+
+// Bus wakes up the watchers of the change feed. It carries no payload:
+// subscribers read the new events from the feed, which also covers changes
+// made by other server instances once they poll.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan struct{}]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value after every Publish, and
+// a function that ends the subscription. Publishes made while the subscriber
+// is busy are coalesced into one.
+func (b *Bus) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Publish tells every subscriber that new events have been committed.
+func (b *Bus) Publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
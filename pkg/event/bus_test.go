@@ -0,0 +1,24 @@
+package event_test
+
+import (
+	"testing"
+
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusCoalescesPublishes(t *testing.T) {
+	bus := event.NewBus()
+
+	ch, cancel := bus.Subscribe()
+	bus.Publish()
+	bus.Publish()
+
+	assert.Len(t, ch, 1)
+	<-ch
+
+	cancel()
+	bus.Publish()
+
+	assert.Len(t, ch, 0)
+}
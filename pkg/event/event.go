@@ -0,0 +1,177 @@
+// Package event implements the change feed behind Watch. Handlers record an
+// event in the todo_event table inside the transaction of every change to a
+// todo, and publish on a Bus once committed to wake up the watchers.
+//
+// An event is recorded without a sequence number. Readers call Assign, which
+// numbers the events of committed changes from the single row of
+// todo_event_sequence in a short transaction of its own. Numbers are only
+// ever given to committed events, in increasing order, so a reader that has
+// seen an event has seen every event before it, and the feed can be
+// replayed by sequence number. The changes themselves never lock the row.
+package event
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	// KindCreated is a todo being created.
+	KindCreated = "created"
+	// KindUpdated is any change to a todo that keeps it out of the trash,
+	// including a restore from the trash.
+	KindUpdated = "updated"
+	// KindDeleted is a todo being moved to the trash.
+	KindDeleted = "deleted"
+)
+
+// Event is a recorded change to a todo.
+type Event struct {
+	Sequence  int64
+	ToDoID    int64
+	ListID    int64
+	Kind      string
+	CreatedAt time.Time
+}
+
+// assignBatchSize caps the events numbered in one transaction
+const assignBatchSize = 500
+
+// Execer is satisfied by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// RowQueryer is satisfied by *sql.DB and *sql.Tx.
+type RowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Record appends an event for a todo to the feed. It must run in the
+// transaction of the change, and the event is numbered by Assign once that
+// commits. The todo's list is taken from the todo row, so it must still
+// exist.
+func Record(ctx context.Context, db Execer, toDoID int64, kind string) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO todo_event(`todo_id`, `list_id`, `kind`, `created_at`) SELECT id, list_id, ?, ? FROM todo WHERE id = ?",
+		kind, time.Now(), toDoID)
+
+	return err
+}
+
+// Assign numbers the events of the changes committed so far, in the order
+// they were recorded. When another reader is numbering events already, it
+// leaves them to it; they are read on a later call.
+func Assign(ctx context.Context, db *sql.DB) error {
+	for {
+		n, err := assign(ctx, db)
+		if err != nil || n < assignBatchSize {
+			return err
+		}
+	}
+}
+
+// assign numbers one batch of events and returns how many it numbered.
+func assign(ctx context.Context, db *sql.DB) (int, error) {
+	// read committed takes no gap locks, so changes recording events are
+	// not held up while numbering
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var last int64
+	err = tx.QueryRowContext(ctx, "SELECT last_seq FROM todo_event_sequence WHERE id = 1 FOR UPDATE SKIP LOCKED").Scan(&last)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// the events of changes still in flight are locked and skipped
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM todo_event WHERE seq IS NULL ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED", assignBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, id := range ids {
+		last++
+		if _, err := tx.ExecContext(ctx, "UPDATE todo_event SET seq = ? WHERE id = ?", last, id); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE todo_event_sequence SET last_seq = ? WHERE id = 1", last); err != nil {
+		return 0, err
+	}
+
+	return len(ids), tx.Commit()
+}
+
+// Sequences returns the last sequence given to an event, whose event and
+// every one before it are committed, and the last sequence purged.
+func Sequences(ctx context.Context, db RowQueryer) (last, purged int64, err error) {
+	err = db.QueryRowContext(ctx, "SELECT last_seq, purged_seq FROM todo_event_sequence WHERE id = 1").Scan(&last, &purged)
+
+	return last, purged, err
+}
+
+// Purge deletes the events recorded before cutoff and returns how many were
+// deleted. Watchers resuming from a sequence before the purged ones have to
+// resync.
+func Purge(ctx context.Context, db *sql.DB, cutoff time.Time) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the sequence is locked so numbering waits for the purge
+	var purged int64
+	if err := tx.QueryRowContext(ctx, "SELECT purged_seq FROM todo_event_sequence WHERE id = 1 FOR UPDATE").Scan(&purged); err != nil {
+		return 0, err
+	}
+
+	var through sql.NullInt64
+	if err := tx.QueryRowContext(ctx, "SELECT MAX(seq) FROM todo_event WHERE created_at < ?", cutoff).Scan(&through); err != nil {
+		return 0, err
+	}
+	if !through.Valid || through.Int64 <= purged {
+		return 0, nil
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM todo_event WHERE seq <= ?", through.Int64)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE todo_event_sequence SET purged_seq = ? WHERE id = 1", through.Int64); err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
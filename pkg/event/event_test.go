@@ -0,0 +1,130 @@
+package event_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/stretchr/testify/assert"
+)
+
+const lockSequenceQuery = "SELECT purged_seq FROM todo_event_sequence WHERE id = 1 FOR UPDATE"
+
+const (
+	claimSequenceQuery = "SELECT last_seq FROM todo_event_sequence WHERE id = 1 FOR UPDATE SKIP LOCKED"
+	unnumberedQuery    = "SELECT id FROM todo_event WHERE seq IS NULL ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED"
+)
+
+// The change only inserts its event, so it takes no lock shared with other
+// changes.
+func TestRecordLeavesSequenceToAssign(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_event(`todo_id`, `list_id`, `kind`, `created_at`) SELECT id, list_id, ?, ? FROM todo WHERE id = ?")).
+		WithArgs(event.KindUpdated, sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	assert.NoError(t, event.Record(context.Background(), db, 7, event.KindUpdated))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// A change committed after a later one is numbered after it: the event of
+// the change still in flight is locked and skipped, and gets the next
+// number once committed.
+func TestAssignNumbersCommittedEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// event 10 is still in flight, event 11 is committed
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(claimSequenceQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq"}).AddRow(4))
+	mock.ExpectQuery(regexp.QuoteMeta(unnumberedQuery)).WithArgs(500).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_event SET seq = ? WHERE id = ?")).WithArgs(5, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_event_sequence SET last_seq = ? WHERE id = 1")).WithArgs(5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, event.Assign(context.Background(), db))
+
+	// event 10 committed since
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(claimSequenceQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq"}).AddRow(5))
+	mock.ExpectQuery(regexp.QuoteMeta(unnumberedQuery)).WithArgs(500).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_event SET seq = ? WHERE id = ?")).WithArgs(6, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_event_sequence SET last_seq = ? WHERE id = 1")).WithArgs(6).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, event.Assign(context.Background(), db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAssignLeavesNumberingToOtherReader(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(claimSequenceQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq"}))
+	mock.ExpectRollback()
+
+	assert.NoError(t, event.Assign(context.Background(), db))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeRecordsWatermark(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cutoff := time.Now().Add(-time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockSequenceQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"purged_seq"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(seq) FROM todo_event WHERE created_at < ?")).WithArgs(cutoff).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(25))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo_event WHERE seq <= ?")).WithArgs(25).
+		WillReturnResult(sqlmock.NewResult(0, 14))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_event_sequence SET purged_seq = ? WHERE id = 1")).WithArgs(25).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deleted, err := event.Purge(context.Background(), db, cutoff)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(14), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeWithoutOldEvents(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockSequenceQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"purged_seq"}).AddRow(10))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MAX(seq) FROM todo_event WHERE created_at < ?")).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+	mock.ExpectRollback()
+
+	deleted, err := event.Purge(context.Background(), db, time.Now())
+
+	assert.NoError(t, err)
+	assert.Zero(t, deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,63 @@
+// Package gateway serves ToDoService as JSON over HTTP at the paths of the
+// google.api.http options in its proto. Each HTTP request is made into a
+// gRPC call to the server, so it goes through the same interceptors as a
+// native call, and an error is answered with the HTTP status of its code,
+// e.g. 404 for NotFound and 409 for Aborted. The address of the HTTP client
+// is passed on as the last entry of the x-forwarded-for metadata.
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/logging"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+)
+
+// forwardedHeaders are the HTTP request headers passed on to the call as
+// metadata under the same name.
+var forwardedHeaders = map[string]bool{
+	util.PrincipalMetadataKey:    true,
+	util.TimeZoneMetadataKey:     true,
+	util.IdempotencyMetadataKey:  true,
+	logging.RequestIDMetadataKey: true,
+	// W3C trace context, so the call continues the caller's trace
+	"traceparent": true,
+	"tracestate":  true,
+}
+
+// NewHandler creates the handler serving ToDoService by calling it on conn.
+func NewHandler(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(incomingHeader),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeader),
+	)
+
+	if err := todo.RegisterToDoServiceHandler(ctx, mux, conn); err != nil {
+		return nil, err
+	}
+
+	return mux, nil
+}
+
+func incomingHeader(key string) (string, bool) {
+	if key = strings.ToLower(key); forwardedHeaders[key] {
+		return key, true
+	}
+
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeader returns the request id as it was sent, and the other
+// response metadata with the gateway's Grpc-Metadata- prefix.
+func outgoingHeader(key string) (string, bool) {
+	if key == logging.RequestIDMetadataKey {
+		return "X-Request-Id", true
+	}
+
+	return runtime.MetadataHeaderPrefix + key, true
+}
@@ -0,0 +1,148 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/gateway"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testServer answers Read and Update the way the service would
+type testServer struct {
+	todo.UnimplementedToDoServiceServer
+	updated      *todo.ToDo
+	forwardedFor []string
+}
+
+func (s *testServer) Read(ctx context.Context, req *todo.ReadToDoRequest) (*todo.ReadToDoResponse, error) {
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", "req-1"))
+	s.forwardedFor = metadata.ValueFromIncomingContext(ctx, "x-forwarded-for")
+
+	if util.PrincipalFromContext(ctx) != "alice" {
+		return nil, status.Error(codes.Unauthenticated, "who are you")
+	}
+	if req.GetId() != 7 {
+		return nil, status.Error(codes.NotFound, "todo not found")
+	}
+
+	return &todo.ReadToDoResponse{ToDo: &todo.ToDo{Id: 7, Title: "Pay rent"}}, nil
+}
+
+func (s *testServer) Update(ctx context.Context, req *todo.UpdateToDoRequest) (*todo.UpdateToDoResponse, error) {
+	s.updated = req.GetToDo()
+	if req.ToDo.GetVersion() != 3 {
+		return nil, status.Error(codes.Aborted, "todo has been modified, current version is 3")
+	}
+
+	return &todo.UpdateToDoResponse{Success: true, Version: 4}, nil
+}
+
+func newGateway(t *testing.T, srv todo.ToDoServiceServer) http.Handler {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	todo.RegisterToDoServiceServer(server, srv)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	h, err := gateway.NewHandler(context.Background(), conn)
+	assert.NoError(t, err)
+
+	return h
+}
+
+func do(h http.Handler, method, path, principal, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if principal != "" {
+		req.Header.Set("X-User-Id", principal)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRead(t *testing.T) {
+	h := newGateway(t, &testServer{})
+
+	rec := do(h, http.MethodGet, "/v1/todos/7", "alice", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get("X-Request-Id"))
+
+	var res struct {
+		ToDo struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+		} `json:"toDo"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "7", res.ToDo.ID)
+	assert.Equal(t, "Pay rent", res.ToDo.Title)
+}
+
+func TestErrorsMapToHTTPStatus(t *testing.T) {
+	h := newGateway(t, &testServer{})
+
+	rec := do(h, http.MethodGet, "/v1/todos/8", "alice", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "todo not found")
+
+	rec = do(h, http.MethodGet, "/v1/todos/7", "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = do(h, http.MethodGet, "/v1/todos/seven", "alice", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do(h, http.MethodPatch, "/v1/todos/7", "alice", `{"title": "Pay rent", "version": "2"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = do(h, http.MethodPost, "/v1/todos/7/comments", "alice", `{"body": "done"}`)
+	assert.Equal(t, http.StatusNotImplemented, rec.Code)
+}
+
+func TestUpdateTakesIDFromPath(t *testing.T) {
+	srv := &testServer{}
+	h := newGateway(t, srv)
+
+	rec := do(h, http.MethodPatch, "/v1/todos/7", "alice", `{"title": "Pay rent", "version": "3"}`)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"success": true, "version": "4", "undoToken": ""}`, rec.Body.String())
+	assert.Equal(t, int64(7), srv.updated.GetId())
+	assert.Equal(t, "Pay rent", srv.updated.GetTitle())
+}
+
+func TestForwardsClientAddress(t *testing.T) {
+	srv := &testServer{}
+	h := newGateway(t, srv)
+
+	// the address the gateway was called from comes last, after any the
+	// client claimed
+	req := httptest.NewRequest(http.MethodGet, "/v1/todos/7", nil)
+	req.RemoteAddr = "198.51.100.7:40000"
+	req.Header.Set("X-User-Id", "alice")
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, []string{"203.0.113.9, 198.51.100.7"}, srv.forwardedFor)
+}
@@ -0,0 +1,126 @@
+// Package health reports whether the server can serve through the standard
+// grpc.health.v1.Health service, so load balancers stop sending calls when
+// the database cannot be reached or the server is shutting down.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// pingTimeout bounds a database ping
+const pingTimeout = 5 * time.Second
+
+// Checker pings the database every interval and sets the status of the
+// services accordingly.
+type Checker struct {
+	server   *grpchealth.Server
+	db       *sql.DB
+	services []string
+	interval time.Duration
+}
+
+// NewChecker creates a checker for services, the full names of the services
+// depending on db. The overall health of the server, the empty service name,
+// follows the same status.
+func NewChecker(db *sql.DB, interval time.Duration, services ...string) *Checker {
+	c := &Checker{
+		server:   grpchealth.NewServer(),
+		db:       db,
+		services: append([]string{""}, services...),
+		interval: interval,
+	}
+
+	// nothing is served until the first check
+	c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	return c
+}
+
+// Register registers the health service on s.
+func (c *Checker) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, c.server)
+}
+
+// Check pings the database once and updates the status of the services.
+func (c *Checker) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := c.db.PingContext(ctx); err != nil {
+		c.set(healthpb.HealthCheckResponse_NOT_SERVING)
+		return err
+	}
+
+	c.set(healthpb.HealthCheckResponse_SERVING)
+
+	return nil
+}
+
+// Run checks the database now and every interval until ctx is done. A failed
+// check is reported to onError, which may be nil.
+func (c *Checker) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Check(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Shutdown reports every service as not serving for good, so load balancers
+// drain the server before it stops.
+func (c *Checker) Shutdown() {
+	c.server.Shutdown()
+}
+
+func (c *Checker) set(s healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range c.services {
+		c.server.SetServingStatus(service, s)
+	}
+}
+
+// isProbe reports whether fullMethod belongs to the health or reflection
+// services.
+func isProbe(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") ||
+		strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+// SkipUnary wraps i so it is not run for calls to the health and reflection
+// services, which load balancers and tools make far too often to audit or
+// log.
+func SkipUnary(i grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isProbe(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		return i(ctx, req, info, handler)
+	}
+}
+
+// SkipStream is SkipUnary for streaming calls.
+func SkipStream(i grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isProbe(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		return i(srv, ss, info, handler)
+	}
+}
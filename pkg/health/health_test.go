@@ -0,0 +1,89 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ariefro/simple-to-do-service/pkg/health"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve registers c on a server and returns a client of it
+func serve(t *testing.T, c *health.Checker) healthpb.HealthClient {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	c.Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func status(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.NoError(t, err)
+
+	return res.GetStatus()
+}
+
+func TestChecker(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	c := health.NewChecker(db, time.Minute, "pb.ToDoService")
+	client := serve(t, c)
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, client, "pb.ToDoService"))
+
+	mock.ExpectPing()
+	assert.NoError(t, c.Check(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, client, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status(t, client, "pb.ToDoService"))
+
+	mock.ExpectPing().WillReturnError(errors.New("database is gone"))
+	assert.Error(t, c.Check(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, client, "pb.ToDoService"))
+
+	// once shutting down the server stays out of rotation
+	c.Shutdown()
+	mock.ExpectPing()
+	assert.NoError(t, c.Check(context.Background()))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, client, ""))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status(t, client, "pb.ToDoService"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSkipUnary(t *testing.T) {
+	var intercepted []string
+	i := health.SkipUnary(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		intercepted = append(intercepted, info.FullMethod)
+		return handler(ctx, req)
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	for _, method := range []string{"/grpc.health.v1.Health/Check", "/pb.ToDoService/Read"} {
+		resp, err := i(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", resp)
+	}
+
+	assert.Equal(t, []string{"/pb.ToDoService/Read"}, intercepted)
+}
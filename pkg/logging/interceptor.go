@@ -0,0 +1,182 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// redacted replaces the value of a redacted field in logged requests
+const redacted = "[REDACTED]"
+
+// DefaultRedactedFields are the request fields left out of the logs unless
+// configured with WithRedactedFields.
+var DefaultRedactedFields = []string{"description"}
+
+// Interceptor logs the calls to a gRPC server.
+type Interceptor struct {
+	logger *slog.Logger
+	redact map[protoreflect.Name]bool
+}
+
+// Option configures an Interceptor.
+type Option func(*Interceptor)
+
+// WithRedactedFields replaces the string fields, matched by name at any
+// depth, whose values are left out of logged requests. With no fields
+// requests are logged in full.
+func WithRedactedFields(fields ...string) Option {
+	return func(i *Interceptor) {
+		i.redact = map[protoreflect.Name]bool{}
+		for _, field := range fields {
+			i.redact[protoreflect.Name(field)] = true
+		}
+	}
+}
+
+// NewInterceptor creates an interceptor logging to logger.
+func NewInterceptor(logger *slog.Logger, opts ...Option) *Interceptor {
+	i := &Interceptor{logger: logger}
+	WithRedactedFields(DefaultRedactedFields...)(i)
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	return i
+}
+
+// UnaryServerInterceptor logs every unary call along with its request.
+func (i *Interceptor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, l, id := i.begin(ctx)
+
+		// there is no transport stream to set a header on in tests
+		grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadataKey, id))
+
+		resp, err := handler(ctx, req)
+		i.finish(ctx, l, info.FullMethod, start, err, req)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor logs every streaming call once it has ended. The
+// messages of a stream are not logged.
+func (i *Interceptor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx, l, id := i.begin(ss.Context())
+
+		ss.SetHeader(metadata.Pairs(RequestIDMetadataKey, id))
+
+		err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		i.finish(ctx, l, info.FullMethod, start, err, nil)
+
+		return err
+	}
+}
+
+// contextStream replaces the context of a stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// begin assigns the call its request id and puts its logger in ctx.
+func (i *Interceptor) begin(ctx context.Context) (context.Context, *slog.Logger, string) {
+	id := requestID(ctx)
+	l := i.logger.With(slog.String("request_id", id))
+
+	return NewContext(ctx, l), l, id
+}
+
+func (i *Interceptor) finish(ctx context.Context, l *slog.Logger, method string, start time.Time, err error, req interface{}) {
+	code := status.Code(err)
+
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(start)),
+		slog.String("principal", util.PrincipalFromContext(ctx)),
+	}
+	if m, ok := req.(proto.Message); ok {
+		attrs = append(attrs, slog.Any("request", payload{m: m, redact: i.redact}))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", status.Convert(err).Message()))
+	}
+
+	l.LogAttrs(ctx, level(code), "finished call", attrs...)
+}
+
+// level is the level a call ending with code is logged at: errors of the
+// server are errors, those of the client warnings.
+func level(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unavailable, codes.Unimplemented:
+		return slog.LevelError
+	default:
+		return slog.LevelWarn
+	}
+}
+
+// payload logs a request in protobuf JSON form with its redacted fields
+// replaced. It is only encoded when the record is actually logged.
+type payload struct {
+	m      proto.Message
+	redact map[protoreflect.Name]bool
+}
+
+func (p payload) LogValue() slog.Value {
+	m := proto.Clone(p.m)
+	redactFields(m.ProtoReflect(), p.redact)
+
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+	if err != nil {
+		return slog.StringValue("failed to encode request: " + err.Error())
+	}
+
+	return slog.StringValue(string(b))
+}
+
+// redactFields replaces the set string fields of m named in names, at any
+// depth.
+func redactFields(m protoreflect.Message, names map[protoreflect.Name]bool) {
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+		case fd.Kind() == protoreflect.MessageKind && fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				redactFields(v.List().Get(i).Message(), names)
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			redactFields(v.Message(), names)
+		case fd.Kind() == protoreflect.StringKind && !fd.IsList() && names[fd.Name()]:
+			fields = append(fields, fd)
+		}
+
+		return true
+	})
+
+	// fields are set once the range is over, as it must not change m
+	for _, fd := range fields {
+		m.Set(fd, protoreflect.ValueOfString(redacted))
+	}
+}
@@ -0,0 +1,70 @@
+// Package logging logs every call to the API with log/slog. Each call gets a
+// request id, taken from the x-request-id metadata when the client sends one,
+// which is returned in the response header and attached to the logger put in
+// the call's context, so handlers log with the same correlation id.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDMetadataKey is the request and response metadata key carrying
+// the request id.
+const RequestIDMetadataKey = "x-request-id"
+
+// maxRequestIDLength is the longest request id accepted from a client
+const maxRequestIDLength = 128
+
+type loggerKey struct{}
+
+// NewContext returns a context carrying l.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of the call ctx belongs to, or the default
+// logger outside of a call.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
+
+// requestID returns the request id sent by the client, or a new one when it
+// sent none or one that does not look like an id.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(RequestIDMetadataKey); len(values) > 0 && validRequestID(values[0]) {
+			return values[0]
+		}
+	}
+
+	b := make([]byte, 16)
+	// crypto/rand does not fail on supported platforms
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether id is short printable ASCII, so it cannot
+// forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,143 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// records decodes the JSON lines written to buf
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var recs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &rec))
+		recs = append(recs, rec)
+	}
+
+	return recs
+}
+
+func createRequest() *todo.CreateToDoRequest {
+	return &todo.CreateToDoRequest{ToDo: &todo.ToDo{Title: "Dentist", Description: "root canal, tell nobody"}}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	i := logging.NewInterceptor(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "alice", "x-request-id", "req-42"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		logging.FromContext(ctx).InfoContext(ctx, "creating todo")
+		return nil, status.Error(codes.InvalidArgument, "reminder is required")
+	}
+
+	_, err := i.UnaryServerInterceptor()(ctx, createRequest(), info, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	recs := records(t, &buf)
+	if assert.Len(t, recs, 2) {
+		// the handler logs with the request id of the call
+		assert.Equal(t, "creating todo", recs[0]["msg"])
+		assert.Equal(t, "req-42", recs[0]["request_id"])
+
+		assert.Equal(t, "WARN", recs[1]["level"])
+		assert.Equal(t, "req-42", recs[1]["request_id"])
+		assert.Equal(t, "/pb.ToDoService/Create", recs[1]["method"])
+		assert.Equal(t, "InvalidArgument", recs[1]["code"])
+		assert.Equal(t, "alice", recs[1]["principal"])
+		assert.Equal(t, "reminder is required", recs[1]["error"])
+		assert.Contains(t, recs[1]["request"], `"title":"Dentist"`)
+		assert.Contains(t, recs[1]["request"], `"description":"[REDACTED]"`)
+	}
+	assert.NotContains(t, buf.String(), "root canal")
+}
+
+func TestUnaryServerInterceptorWithoutRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	i := logging.NewInterceptor(slog.New(slog.NewJSONHandler(&buf, nil)), logging.WithRedactedFields())
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &todo.CreateToDoResponse{Id: 1}, nil
+	}
+
+	req := createRequest()
+	_, err := i.UnaryServerInterceptor()(context.Background(), req, info, handler)
+	assert.NoError(t, err)
+
+	recs := records(t, &buf)
+	assert.Equal(t, "INFO", recs[0]["level"])
+	assert.Contains(t, recs[0]["request"], "root canal")
+	// the request itself is left alone
+	assert.Equal(t, "root canal, tell nobody", req.ToDo.Description)
+}
+
+func TestRequestIDIsGenerated(t *testing.T) {
+	var buf bytes.Buffer
+	i := logging.NewInterceptor(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Read"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	}
+
+	// an id spanning lines could forge log entries
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "a\nb"))
+	_, err := i.UnaryServerInterceptor()(ctx, &todo.ReadToDoRequest{Id: 1}, info, handler)
+	assert.NoError(t, err)
+
+	recs := records(t, &buf)
+	assert.Len(t, recs[0]["request_id"], 32)
+}
+
+// testStream is a server stream with nothing to send or receive
+type testStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	i := logging.NewInterceptor(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	stream := &testStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-7"))}
+	info := &grpc.StreamServerInfo{FullMethod: "/pb.ToDoService/Watch"}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		logging.FromContext(ss.Context()).Info("watching")
+		return status.Error(codes.Internal, "database is gone")
+	}
+
+	err := i.StreamServerInterceptor()(nil, stream, info, handler)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	assert.Equal(t, []string{"req-7"}, stream.header.Get("x-request-id"))
+	recs := records(t, &buf)
+	if assert.Len(t, recs, 2) {
+		assert.Equal(t, "req-7", recs[0]["request_id"])
+		assert.Equal(t, "ERROR", recs[1]["level"])
+		assert.NotContains(t, recs[1], "request")
+	}
+}
@@ -0,0 +1,175 @@
+// Package metrics exports the Prometheus metrics of the service: calls to
+// the API, the database pool, notification delivery and figures about the
+// todos read from the database on every scrape.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ariefro/simple-to-do-service/pkg/notify"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// scrapeTimeout bounds the database queries made for a scrape
+const scrapeTimeout = 5 * time.Second
+
+// Metrics holds the metrics of the service in its own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	handled  *prometheus.CounterVec
+	handling *prometheus.HistogramVec
+
+	delivered   *prometheus.CounterVec
+	failed      *prometheus.CounterVec
+	deliveryLag prometheus.Histogram
+}
+
+// New creates the metrics of a service using db.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Calls handled by the server, by method and status code.",
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		handling: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Time taken to handle calls, by method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"grpc_service", "grpc_method", "grpc_code"}),
+		delivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "todo_notifications_delivered_total",
+			Help: "Notifications delivered, by kind.",
+		}, []string{"kind"}),
+		failed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "todo_notifications_failed_total",
+			Help: "Notification deliveries that failed and will be retried, by kind.",
+		}, []string{"kind"}),
+		deliveryLag: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "todo_notification_delivery_lag_seconds",
+			Help:    "Time between a notification falling due and its delivery.",
+			Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "todo"),
+		newStatsCollector(db),
+		m.handled,
+		m.handling,
+		m.delivered,
+		m.failed,
+		m.deliveryLag,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		// a failing database query leaves out its metrics, not all of them
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// UnaryServerInterceptor counts and times every unary call.
+func (m *Metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observe(info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor counts and times every streaming call.
+func (m *Metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		m.observe(info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func (m *Metrics) observe(fullMethod string, start time.Time, err error) {
+	// full methods look like /pb.ToDoService/Create
+	svc, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	code := status.Code(err).String()
+
+	m.handled.WithLabelValues(svc, method, code).Inc()
+	m.handling.WithLabelValues(svc, method, code).Observe(time.Since(start).Seconds())
+}
+
+// Delivered implements notify.Observer.
+func (m *Metrics) Delivered(n notify.Notification, lag time.Duration) {
+	m.delivered.WithLabelValues(n.Kind).Inc()
+	m.deliveryLag.Observe(lag.Seconds())
+}
+
+// Failed implements notify.Observer.
+func (m *Metrics) Failed(n notify.Notification, err error) {
+	m.failed.WithLabelValues(n.Kind).Inc()
+}
+
+// statsCollector reads the todo and scheduler figures on every scrape.
+type statsCollector struct {
+	db *sql.DB
+
+	open         *prometheus.Desc
+	overdue      *prometheus.Desc
+	schedulerLag *prometheus.Desc
+}
+
+func newStatsCollector(db *sql.DB) *statsCollector {
+	return &statsCollector{
+		db:           db,
+		open:         prometheus.NewDesc("todo_todos_open", "Todos outside the trash.", nil, nil),
+		overdue:      prometheus.NewDesc("todo_todos_overdue", "Todos outside the trash past their due date.", nil, nil),
+		schedulerLag: prometheus.NewDesc("todo_notification_scheduler_lag_seconds", "How long the oldest due notification has been waiting for delivery.", nil, nil),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.open
+	ch <- c.overdue
+	ch <- c.schedulerLag
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+	defer cancel()
+
+	now := time.Now()
+
+	stats, err := service.CountToDos(ctx, c.db, now)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.open, err)
+		ch <- prometheus.NewInvalidMetric(c.overdue, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.Open))
+		ch <- prometheus.MustNewConstMetric(c.overdue, prometheus.GaugeValue, float64(stats.Overdue))
+	}
+
+	lag, err := notify.SchedulerLag(ctx, c.db, now)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.schedulerLag, err)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.schedulerLag, prometheus.GaugeValue, lag.Seconds())
+	}
+}
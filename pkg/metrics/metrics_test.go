@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ariefro/simple-to-do-service/pkg/metrics"
+	"github.com/ariefro/simple-to-do-service/pkg/notify"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// scrape returns the metrics served by m
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	b, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+
+	return string(b)
+}
+
+func TestMetrics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := metrics.New(db)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.ToDoService/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.InvalidArgument, "title is required")
+	}
+	_, err = m.UnaryServerInterceptor()(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	m.Delivered(notify.Notification{Kind: notify.KindReminder}, 3*time.Second)
+	m.Failed(notify.Notification{Kind: notify.KindMention}, errors.New("mailbox full"))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*), COALESCE(SUM(")).
+		WillReturnRows(sqlmock.NewRows([]string{"open", "overdue"}).AddRow(12, 4))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MIN(n.deliver_at) FROM notification n")).
+		WillReturnRows(sqlmock.NewRows([]string{"oldest"}).AddRow(time.Now().Add(-time.Minute)))
+
+	body := scrape(t, m)
+
+	assert.Contains(t, body, `grpc_server_handled_total{grpc_code="InvalidArgument",grpc_method="Create",grpc_service="pb.ToDoService"} 1`)
+	assert.Contains(t, body, `grpc_server_handling_seconds_count{grpc_code="InvalidArgument",grpc_method="Create",grpc_service="pb.ToDoService"} 1`)
+	assert.Contains(t, body, `todo_notifications_delivered_total{kind="reminder"} 1`)
+	assert.Contains(t, body, `todo_notifications_failed_total{kind="mention"} 1`)
+	assert.Contains(t, body, "todo_notification_delivery_lag_seconds_sum 3")
+	assert.Contains(t, body, "todo_todos_open 12")
+	assert.Contains(t, body, "todo_todos_overdue 4")
+	assert.Contains(t, body, "todo_notification_scheduler_lag_seconds 60")
+	assert.Contains(t, body, "go_goroutines")
+	assert.Contains(t, body, `go_sql_open_connections{db_name="todo"}`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMetricsWithFailingDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	m := metrics.New(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*)")).WillReturnError(errors.New("database is gone"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT MIN(n.deliver_at)")).WillReturnError(errors.New("database is gone"))
+
+	body := scrape(t, m)
+
+	// the other metrics are still served
+	assert.NotContains(t, body, "todo_todos_open")
+	assert.Contains(t, body, "go_goroutines")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
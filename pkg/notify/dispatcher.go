@@ -0,0 +1,151 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	// a notification whose delivery fails is retried after minRetryDelay,
+	// doubling with every attempt up to maxRetryDelay
+	minRetryDelay = time.Minute
+	maxRetryDelay = 6 * time.Hour
+)
+
+// retryDelay returns how long to wait before retrying a notification that
+// has failed attempts times, including the attempt that just failed.
+func retryDelay(attempts int) time.Duration {
+	delay := minRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryDelay)
+}
+
+// Dispatcher periodically delivers due notifications. Several dispatchers may
+// run against the same database; rows being delivered are locked and skipped
+// by the others.
+type Dispatcher struct {
+	db        *sql.DB
+	sender    Sender
+	interval  time.Duration
+	batchSize int
+
+	// observer, when set, is told about every delivery attempt
+	observer Observer
+}
+
+// Observer is told about the delivery attempts of a Dispatcher, e.g. to
+// export metrics.
+type Observer interface {
+	// Delivered is called for a notification delivered lag after it was
+	// due.
+	Delivered(n Notification, lag time.Duration)
+	// Failed is called for a notification whose delivery failed.
+	Failed(n Notification, err error)
+}
+
+func NewDispatcher(db *sql.DB, sender Sender, interval time.Duration) *Dispatcher {
+	return &Dispatcher{
+		db:        db,
+		sender:    sender,
+		interval:  interval,
+		batchSize: 100,
+	}
+}
+
+// SetObserver makes d report its delivery attempts to o.
+func (d *Dispatcher) SetObserver(o Observer) {
+	d.observer = o
+}
+
+// Run delivers due notifications every interval until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		// keep draining while full batches come back
+		for {
+			n, err := d.DispatchDue(ctx, time.Now())
+			if err != nil || n < d.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue delivers one batch of notifications due at now and returns how
+// many were delivered. A notification whose delivery fails stays queued and
+// is retried after a delay growing with every failed attempt; until then it
+// is skipped, so the notifications due after it are still delivered.
+func (d *Dispatcher) DispatchDue(ctx context.Context, now time.Time) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// notifications of trashed todos wait until the todo is restored or purged
+	rows, err := tx.QueryContext(ctx, "SELECT n.id, n.recipient, n.kind, n.todo_id, n.message, n.deliver_at, n.attempts FROM notification n "+
+		"JOIN todo t ON t.id = n.todo_id AND t.deleted_at IS NULL "+
+		"WHERE n.delivered_at IS NULL AND n.deliver_at <= ? AND (n.next_attempt_at IS NULL OR n.next_attempt_at <= ?) "+
+		"ORDER BY n.deliver_at LIMIT ? FOR UPDATE OF n SKIP LOCKED", now, now, d.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var due []Notification
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Recipient, &n.Kind, &n.ToDoID, &n.Message, &n.DeliverAt, &n.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var delivered []Notification
+	for _, n := range due {
+		if err := d.sender.Send(ctx, n); err != nil {
+			if d.observer != nil {
+				d.observer.Failed(n, err)
+			}
+
+			retryAt := now.Add(retryDelay(n.Attempts + 1))
+			if _, err := tx.ExecContext(ctx, "UPDATE notification SET attempts = attempts + 1, next_attempt_at = ? WHERE id = ?", retryAt, n.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE notification SET delivered_at = ? WHERE id = ?", now, n.ID); err != nil {
+			return 0, err
+		}
+		delivered = append(delivered, n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if d.observer != nil {
+		for _, n := range delivered {
+			d.observer.Delivered(n, now.Sub(n.DeliverAt))
+		}
+	}
+
+	return len(delivered), nil
+}
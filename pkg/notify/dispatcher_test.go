@@ -0,0 +1,80 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ariefro/simple-to-do-service/pkg/notify"
+	"github.com/stretchr/testify/assert"
+)
+
+const dueQuery = "SELECT n.id, n.recipient, n.kind, n.todo_id, n.message, n.deliver_at, n.attempts FROM notification n " +
+	"JOIN todo t ON t.id = n.todo_id AND t.deleted_at IS NULL " +
+	"WHERE n.delivered_at IS NULL AND n.deliver_at <= ? AND (n.next_attempt_at IS NULL OR n.next_attempt_at <= ?) " +
+	"ORDER BY n.deliver_at LIMIT ? FOR UPDATE OF n SKIP LOCKED"
+
+var dueColumns = []string{"id", "recipient", "kind", "todo_id", "message", "deliver_at", "attempts"}
+
+// testObserver records the recipients of the delivery attempts it is told of
+type testObserver struct {
+	delivered, failed []string
+	lags              []time.Duration
+}
+
+func (o *testObserver) Delivered(n notify.Notification, lag time.Duration) {
+	o.delivered = append(o.delivered, n.Recipient)
+	o.lags = append(o.lags, lag)
+}
+
+func (o *testObserver) Failed(n notify.Notification, err error) {
+	o.failed = append(o.failed, n.Recipient)
+}
+
+func TestDispatchDue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	var sent []string
+	sender := notify.SenderFunc(func(ctx context.Context, n notify.Notification) error {
+		if n.Recipient == "bob" {
+			return errors.New("mailbox full")
+		}
+		sent = append(sent, n.Recipient)
+		return nil
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(dueQuery)).
+		WithArgs(now, now, 100).
+		WillReturnRows(sqlmock.NewRows(dueColumns).
+			AddRow(1, "alice", notify.KindReminder, 7, "pay rent", now.Add(-time.Minute), 0).
+			AddRow(2, "bob", notify.KindMention, 7, "alice mentioned you", now, 2))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notification SET delivered_at = ? WHERE id = ?")).
+		WithArgs(now, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// bob's notification stays queued and is retried after its third
+	// failure, four minutes from now
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notification SET attempts = attempts + 1, next_attempt_at = ? WHERE id = ?")).
+		WithArgs(now.Add(4*time.Minute), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	observer := &testObserver{}
+	d := notify.NewDispatcher(db, sender, time.Minute)
+	d.SetObserver(observer)
+	n, err := d.DispatchDue(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"alice"}, sent)
+	assert.Equal(t, []string{"alice"}, observer.delivered)
+	assert.Equal(t, []time.Duration{time.Minute}, observer.lags)
+	assert.Equal(t, []string{"bob"}, observer.failed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
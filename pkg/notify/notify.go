@@ -0,0 +1,78 @@
+// Package notify implements the notification pipeline. Handlers enqueue
+// notifications into the notification table, usually inside the transaction
+// of the change that caused them, and a Dispatcher delivers them once they
+// are due.
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	// KindReminder is a todo reminder coming due.
+	KindReminder = "reminder"
+	// KindMention is a user being mentioned in a comment.
+	KindMention = "mention"
+)
+
+// Notification is a message queued for a single recipient.
+type Notification struct {
+	ID        int64
+	Recipient string
+	Kind      string
+	ToDoID    int64
+	Message   string
+	DeliverAt time.Time
+	// Attempts is the number of failed deliveries so far.
+	Attempts int
+}
+
+// Sender delivers a notification to its recipient, e.g. by email or push.
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// SenderFunc adapts a function to the Sender interface.
+type SenderFunc func(ctx context.Context, n Notification) error
+
+func (f SenderFunc) Send(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// Execer is satisfied by *sql.DB and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Enqueue queues a notification for delivery at n.DeliverAt.
+func Enqueue(ctx context.Context, db Execer, n Notification) error {
+	_, err := db.ExecContext(ctx, "INSERT INTO notification(`recipient`, `kind`, `todo_id`, `message`, `deliver_at`) VALUES (?, ?, ?, ?, ?)",
+		n.Recipient, n.Kind, n.ToDoID, n.Message, n.DeliverAt)
+
+	return err
+}
+
+// CancelPending drops the undelivered notifications of a kind for a todo,
+// e.g. when its reminder is moved.
+func CancelPending(ctx context.Context, db Execer, toDoID int64, kind string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM notification WHERE todo_id = ? AND kind = ? AND delivered_at IS NULL", toDoID, kind)
+
+	return err
+}
+
+// SchedulerLag returns how long the oldest notification due at now has been
+// waiting for delivery, zero when none is waiting. Notifications of trashed
+// todos are not waiting.
+func SchedulerLag(ctx context.Context, db *sql.DB, now time.Time) (time.Duration, error) {
+	var oldest sql.NullTime
+	err := db.QueryRowContext(ctx, "SELECT MIN(n.deliver_at) FROM notification n "+
+		"JOIN todo t ON t.id = n.todo_id AND t.deleted_at IS NULL "+
+		"WHERE n.delivered_at IS NULL AND n.deliver_at <= ?", now).Scan(&oldest)
+	if err != nil || !oldest.Valid {
+		return 0, err
+	}
+
+	return now.Sub(oldest.Time), nil
+}
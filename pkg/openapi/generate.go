@@ -0,0 +1,390 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	title   = "simple-to-do-service"
+	version = "v1"
+)
+
+// object is a JSON object of the document. Maps marshal with sorted keys, so
+// the same descriptors always give the same document.
+type object = map[string]interface{}
+
+// pathVariable matches a variable of a path template, e.g. {to_do.id}
+var pathVariable = regexp.MustCompile(`\{([\w.]+)(?:=[^}]*)?\}`)
+
+// Generate returns the OpenAPI 3 document, in JSON, of the HTTP bindings of
+// the methods of the services in fd. Descriptions are taken from the comments
+// in fd when it carries its source info, as it does in a protoc plugin; the
+// descriptors linked into a binary do not.
+func Generate(fd protoreflect.FileDescriptor) ([]byte, error) {
+	g := &generator{fd: fd, schemas: object{}}
+
+	paths := object{}
+	var tags []interface{}
+	for i := 0; i < fd.Services().Len(); i++ {
+		sd := fd.Services().Get(i)
+
+		bound := false
+		for j := 0; j < sd.Methods().Len(); j++ {
+			md := sd.Methods().Get(j)
+			rule, _ := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+			if rule == nil {
+				continue
+			}
+			bound = true
+
+			for k, r := range append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...) {
+				method, template := pattern(r)
+				if method == "" {
+					return nil, fmt.Errorf("%s: unsupported HTTP pattern", md.FullName())
+				}
+
+				id := string(sd.Name()) + "_" + string(md.Name())
+				if k > 0 {
+					id += strconv.Itoa(k)
+				}
+
+				op, err := g.operation(sd, md, r, template, id)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", md.FullName(), err)
+				}
+
+				path := pathVariable.ReplaceAllString(template, "{$1}")
+				if paths[path] == nil {
+					paths[path] = object{}
+				}
+				paths[path].(object)[strings.ToLower(method)] = op
+			}
+		}
+
+		if bound {
+			tags = append(tags, withDescription(object{"name": string(sd.Name())}, g.comments(sd)))
+		}
+	}
+
+	doc := object{
+		"openapi": "3.0.3",
+		"info":    object{"title": title, "version": version},
+		"tags":    tags,
+		"paths":   paths,
+		"components": object{
+			"schemas": g.schemas,
+		},
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// comments hold < and > as in due:<7d, which need no escaping
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// pattern returns the HTTP method and path template of r.
+func pattern(r *annotations.HttpRule) (string, string) {
+	switch p := r.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	default:
+		return "", ""
+	}
+}
+
+type generator struct {
+	fd      protoreflect.FileDescriptor
+	schemas object
+}
+
+func (g *generator) operation(sd protoreflect.ServiceDescriptor, md protoreflect.MethodDescriptor, r *annotations.HttpRule, template, id string) (object, error) {
+	in := md.Input()
+
+	// fields bound to the path or the body are not query parameters
+	bound := map[string]bool{}
+	params := []interface{}{}
+	for _, m := range pathVariable.FindAllStringSubmatch(template, -1) {
+		f, err := fieldByPath(in, m[1])
+		if err != nil {
+			return nil, err
+		}
+		bound[m[1]] = true
+		params = append(params, withDescription(object{
+			"name":     m[1],
+			"in":       "path",
+			"required": true,
+			"schema":   g.fieldSchema(f),
+		}, g.comments(f)))
+	}
+
+	op := object{
+		"operationId": id,
+		"tags":        []string{string(sd.Name())},
+		"responses": object{
+			"200": object{
+				"description": "OK",
+				"content":     jsonContent(g.ref(md.Output())),
+			},
+			"default": object{
+				"description": "An error, with the HTTP status of its gRPC code.",
+				"content":     jsonContent(g.ref(statusDescriptor(g.fd))),
+			},
+		},
+	}
+
+	switch body := r.GetBody(); body {
+	case "":
+		params = g.queryParams(in, "", "", bound, map[protoreflect.FullName]bool{}, params)
+	case "*":
+		op["requestBody"] = object{"required": true, "content": jsonContent(g.ref(in))}
+	default:
+		f := in.Fields().ByName(protoreflect.Name(body))
+		if f == nil {
+			return nil, fmt.Errorf("no body field %q in %s", body, in.FullName())
+		}
+		bound[body] = true
+		op["requestBody"] = object{"required": true, "content": jsonContent(g.fieldSchema(f))}
+		params = g.queryParams(in, "", "", bound, map[protoreflect.FullName]bool{}, params)
+	}
+
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	return withDescription(op, g.comments(md)), nil
+}
+
+// queryParams appends the query parameters setting the fields of md not in
+// bound. Fields of nested messages are set with dotted names, as in
+// filter.dueBefore.
+func (g *generator) queryParams(md protoreflect.MessageDescriptor, prefix, protoPrefix string, bound map[string]bool, seen map[protoreflect.FullName]bool, params []interface{}) []interface{} {
+	seen[md.FullName()] = true
+	defer delete(seen, md.FullName())
+
+	for i := 0; i < md.Fields().Len(); i++ {
+		f := md.Fields().Get(i)
+		name, protoName := prefix+f.JSONName(), protoPrefix+string(f.Name())
+		if bound[protoName] || f.IsMap() {
+			continue
+		}
+
+		if f.Kind() == protoreflect.MessageKind && wellKnownSchema(f.Message()) == nil {
+			if !f.IsList() && !seen[f.Message().FullName()] {
+				params = g.queryParams(f.Message(), name+".", protoName+".", bound, seen, params)
+			}
+			continue
+		}
+
+		params = append(params, withDescription(object{
+			"name":   name,
+			"in":     "query",
+			"schema": g.fieldSchema(f),
+		}, g.comments(f)))
+	}
+
+	return params
+}
+
+// ref returns a reference to the schema of md, adding it to the components
+// on first use.
+func (g *generator) ref(md protoreflect.MessageDescriptor) object {
+	if s := wellKnownSchema(md); s != nil {
+		return s
+	}
+
+	name := schemaName(md)
+	if _, ok := g.schemas[name]; !ok {
+		// set before the fields, which may refer back to md
+		g.schemas[name] = nil
+
+		props := object{}
+		for i := 0; i < md.Fields().Len(); i++ {
+			f := md.Fields().Get(i)
+			props[f.JSONName()] = withDescription(g.fieldSchema(f), g.comments(f))
+		}
+
+		g.schemas[name] = withDescription(object{"type": "object", "properties": props}, g.comments(md))
+	}
+
+	return object{"$ref": "#/components/schemas/" + name}
+}
+
+func (g *generator) fieldSchema(f protoreflect.FieldDescriptor) object {
+	switch {
+	case f.IsMap():
+		return object{"type": "object", "additionalProperties": g.valueSchema(f.MapValue())}
+	case f.IsList():
+		return object{"type": "array", "items": g.valueSchema(f)}
+	default:
+		return g.valueSchema(f)
+	}
+}
+
+// valueSchema is the schema of a single value of f.
+func (g *generator) valueSchema(f protoreflect.FieldDescriptor) object {
+	switch f.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return g.ref(f.Message())
+	case protoreflect.EnumKind:
+		var values []string
+		for i := 0; i < f.Enum().Values().Len(); i++ {
+			values = append(values, string(f.Enum().Values().Get(i).Name()))
+		}
+		return object{"type": "string", "enum": values}
+	default:
+		return scalarSchema(f.Kind())
+	}
+}
+
+// scalarSchema is the schema of a scalar in the protobuf JSON mapping, where
+// 64-bit integers are strings.
+func scalarSchema(k protoreflect.Kind) object {
+	switch k {
+	case protoreflect.BoolKind:
+		return object{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return object{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return object{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return object{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return object{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return object{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return object{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return object{"type": "string", "format": "byte"}
+	default:
+		return object{"type": "string"}
+	}
+}
+
+// wellKnownSchema is the schema of a well-known type with a JSON mapping of
+// its own, or nil for other messages.
+func wellKnownSchema(md protoreflect.MessageDescriptor) object {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return object{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return object{"type": "string", "example": "3.5s"}
+	case "google.protobuf.FieldMask":
+		return object{"type": "string", "example": "title,dueAt"}
+	case "google.protobuf.Struct":
+		return object{"type": "object", "additionalProperties": true}
+	case "google.protobuf.Value":
+		return object{}
+	case "google.protobuf.ListValue":
+		return object{"type": "array", "items": object{}}
+	case "google.protobuf.Empty":
+		return object{"type": "object"}
+	case "google.protobuf.Any":
+		return object{
+			"type":                 "object",
+			"properties":           object{"@type": object{"type": "string"}},
+			"additionalProperties": true,
+		}
+	case "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.Int64Value",
+		"google.protobuf.UInt64Value", "google.protobuf.FloatValue", "google.protobuf.DoubleValue":
+		return scalarSchema(md.Fields().ByName("value").Kind())
+	default:
+		return nil
+	}
+}
+
+// schemaName names the schema of md, without the package for messages of
+// the API's own package.
+func schemaName(md protoreflect.MessageDescriptor) string {
+	name := string(md.FullName())
+	if pkg := string(md.ParentFile().Package()); md.ParentFile().Package() != "" && strings.HasPrefix(name, pkg+".") && !strings.HasPrefix(pkg, "google.") {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+
+	return name
+}
+
+// statusDescriptor returns the descriptor of google.rpc.Status, the body of
+// every error response, from the imports of fd.
+func statusDescriptor(fd protoreflect.FileDescriptor) protoreflect.MessageDescriptor {
+	for i := 0; i < fd.Imports().Len(); i++ {
+		if md := fd.Imports().Get(i).Messages().ByName("Status"); md != nil && md.FullName() == "google.rpc.Status" {
+			return md
+		}
+	}
+
+	// the file does not use it itself; the gateway still answers with it
+	return (&status.Status{}).ProtoReflect().Descriptor()
+}
+
+// fieldByPath returns the field of md at a dotted path such as to_do.id.
+func fieldByPath(md protoreflect.MessageDescriptor, path string) (protoreflect.FieldDescriptor, error) {
+	var f protoreflect.FieldDescriptor
+	for i, name := range strings.Split(path, ".") {
+		if i > 0 {
+			if f.Kind() != protoreflect.MessageKind || f.IsList() {
+				return nil, fmt.Errorf("path variable %q is not a field path", path)
+			}
+			md = f.Message()
+		}
+
+		if f = md.Fields().ByName(protoreflect.Name(name)); f == nil {
+			return nil, fmt.Errorf("no field %q in %s", name, md.FullName())
+		}
+	}
+
+	return f, nil
+}
+
+// comments returns the leading comments of d.
+func (g *generator) comments(d protoreflect.Descriptor) string {
+	c := d.ParentFile().SourceLocations().ByDescriptor(d).LeadingComments
+	if c == "" {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimSuffix(c, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func withDescription(o object, description string) object {
+	if description != "" {
+		o["description"] = description
+	}
+
+	return o
+}
+
+func jsonContent(schema object) object {
+	return object{"application/json": object{"schema": schema}}
+}
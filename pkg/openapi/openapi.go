@@ -0,0 +1,33 @@
+// Package openapi serves the OpenAPI 3 document of the JSON gateway, which
+// protoc-gen-openapi generates from the google.api.http options in the proto,
+// along with a page browsing it that needs nothing outside the binary.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI document served at /openapi.json.
+//
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed index.html
+var page []byte
+
+// SpecHandler serves Spec.
+func SpecHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(Spec)
+	})
+}
+
+// UIHandler serves the page browsing the document at /openapi.json.
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
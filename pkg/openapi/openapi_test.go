@@ -0,0 +1,96 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/openapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// withoutDescriptions decodes a document leaving out its descriptions, which
+// come from comments the linked descriptors do not carry.
+func withoutDescriptions(t *testing.T, b []byte) interface{} {
+	var doc interface{}
+	assert.NoError(t, json.Unmarshal(b, &doc))
+
+	var strip func(v interface{})
+	strip = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, e := range v {
+				// a property called description is an object, not a string
+				if _, ok := e.(string); ok && k == "description" {
+					delete(v, k)
+					continue
+				}
+				strip(e)
+			}
+		case []interface{}:
+			for _, e := range v {
+				strip(e)
+			}
+		}
+	}
+	strip(doc)
+
+	return doc
+}
+
+func TestSpecIsUpToDate(t *testing.T) {
+	generated, err := openapi.Generate(todo.File_todos_to_do_service_proto)
+	assert.NoError(t, err)
+
+	assert.Equal(t, withoutDescriptions(t, generated), withoutDescriptions(t, openapi.Spec),
+		"openapi.json does not match the proto, run make proto")
+}
+
+func TestSpec(t *testing.T) {
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(openapi.Spec, &doc))
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, "ToDoService_Create", doc.Paths["/v1/todos"]["post"].OperationID)
+	assert.Equal(t, "ToDoService_ReadAll", doc.Paths["/v1/todos"]["get"].OperationID)
+	assert.Equal(t, "ToDoService_ExecuteView1", doc.Paths["/v1/views/system/{system_key}:execute"]["get"].OperationID)
+
+	update := doc.Paths["/v1/todos/{to_do.id}"]["patch"]
+	assert.Equal(t, "ToDoService_Update", update.OperationID)
+	if assert.Len(t, update.Parameters, 1) {
+		assert.Equal(t, "to_do.id", update.Parameters[0].Name)
+		assert.Equal(t, "path", update.Parameters[0].In)
+	}
+
+	// streaming calls have no HTTP binding
+	for _, methods := range doc.Paths {
+		for _, op := range methods {
+			assert.NotEqual(t, "ToDoService_StreamAll", op.OperationID)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	openapi.SpecHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.Equal(t, openapi.Spec, rec.Body.Bytes())
+
+	rec = httptest.NewRecorder()
+	openapi.UIHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), `fetch("/openapi.json")`)
+	// the page works offline
+	assert.NotContains(t, rec.Body.String(), "<script src=")
+}
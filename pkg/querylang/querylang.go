@@ -0,0 +1,197 @@
+// Package querylang parses the filter language typed into search boxes, such
+// as
+//
+//	due:<7d priority:>=2 !overdue "quarterly report"
+//
+// A query is a list of terms separated by spaces, all of which have to
+// match. A term is a word, a "quoted phrase" or a field:value pair, and a
+// leading ! negates it. Values may start with one of the operators <, <=, >,
+// >= or = and may be quoted. What fields exist and what their values mean is
+// up to the caller.
+package querylang
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Op compares a field with its value.
+type Op string
+
+const (
+	OpEq Op = "="
+	OpLt Op = "<"
+	OpLe Op = "<="
+	OpGt Op = ">"
+	OpGe Op = ">="
+)
+
+// Term is one term of a query. Positions are in characters from the start
+// of the query.
+type Term struct {
+	Pos     int
+	Negated bool
+	// Field is empty for words and phrases.
+	Field string
+	// Op is OpEq unless an operator was given.
+	Op       Op
+	Value    string
+	ValuePos int
+	// Quoted is set when the value was a quoted phrase.
+	Quoted bool
+}
+
+// Error is a syntax error, or an error the caller found in a term.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// Errorf returns an error at position pos.
+func Errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Parse splits a query into terms. Errors are of type *Error.
+func Parse(q string) ([]Term, error) {
+	p := &parser{q: q}
+
+	var terms []Term
+	for {
+		p.skipSpace()
+		if p.i == len(q) {
+			return terms, nil
+		}
+
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+}
+
+type parser struct {
+	q string
+	i int
+}
+
+// pos returns the position of byte offset i in characters.
+func (p *parser) pos(i int) int {
+	return utf8.RuneCountInString(p.q[:i])
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.q) {
+		r, size := utf8.DecodeRuneInString(p.q[p.i:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.i += size
+	}
+}
+
+func (p *parser) atEnd() bool {
+	if p.i == len(p.q) {
+		return true
+	}
+
+	r, _ := utf8.DecodeRuneInString(p.q[p.i:])
+	return unicode.IsSpace(r)
+}
+
+func (p *parser) term() (Term, error) {
+	t := Term{Pos: p.pos(p.i), Op: OpEq}
+
+	if p.q[p.i] == '!' {
+		t.Negated = true
+		p.i++
+		if p.atEnd() {
+			return Term{}, Errorf(t.Pos, "nothing to negate")
+		}
+	}
+
+	if p.q[p.i] == '"' {
+		t.ValuePos = p.pos(p.i)
+		value, err := p.phrase()
+		if err != nil {
+			return Term{}, err
+		}
+		t.Value, t.Quoted = value, true
+		return t, nil
+	}
+
+	start := p.i
+	word := p.word()
+	colon := strings.IndexByte(word, ':')
+	if colon < 0 {
+		t.Value, t.ValuePos = word, p.pos(start)
+		return t, nil
+	}
+
+	t.Field = strings.ToLower(word[:colon])
+	if t.Field == "" {
+		return Term{}, Errorf(p.pos(start), "missing field name")
+	}
+
+	// the value starts after the colon, not at the end of the word
+	p.i = start + colon + 1
+	for _, op := range []Op{OpLe, OpGe, OpLt, OpGt, OpEq} {
+		if strings.HasPrefix(p.q[p.i:], string(op)) {
+			t.Op = op
+			p.i += len(op)
+			break
+		}
+	}
+
+	t.ValuePos = p.pos(p.i)
+	if p.i < len(p.q) && p.q[p.i] == '"' {
+		value, err := p.phrase()
+		if err != nil {
+			return Term{}, err
+		}
+		t.Value, t.Quoted = value, true
+	} else {
+		t.Value = p.word()
+	}
+
+	if t.Value == "" {
+		return Term{}, Errorf(t.ValuePos, "missing value for %s", t.Field)
+	}
+
+	return t, nil
+}
+
+// word reads up to the next space or quote.
+func (p *parser) word() string {
+	start := p.i
+	for p.i < len(p.q) && p.q[p.i] != '"' && !p.atEnd() {
+		_, size := utf8.DecodeRuneInString(p.q[p.i:])
+		p.i += size
+	}
+
+	return p.q[start:p.i]
+}
+
+// phrase reads a quoted phrase, which must be followed by a space or the end
+// of the query.
+func (p *parser) phrase() (string, error) {
+	start := p.i
+	end := strings.IndexByte(p.q[start+1:], '"')
+	if end < 0 {
+		return "", Errorf(p.pos(start), "unterminated phrase")
+	}
+
+	p.i = start + 1 + end + 1
+	if !p.atEnd() {
+		return "", Errorf(p.pos(p.i), "expected a space after the phrase")
+	}
+
+	return p.q[start+1 : start+1+end], nil
+}
@@ -0,0 +1,55 @@
+package querylang_test
+
+import (
+	"testing"
+
+	"github.com/ariefro/simple-to-do-service/pkg/querylang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	terms, err := querylang.Parse(`due:<7d label:"deep work" !done  Priority:>=2 "quarterly report" draft*`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []querylang.Term{
+		{Pos: 0, Field: "due", Op: querylang.OpLt, Value: "7d", ValuePos: 5},
+		{Pos: 8, Field: "label", Op: querylang.OpEq, Value: "deep work", ValuePos: 14, Quoted: true},
+		{Pos: 26, Negated: true, Op: querylang.OpEq, Value: "done", ValuePos: 27},
+		{Pos: 33, Field: "priority", Op: querylang.OpGe, Value: "2", ValuePos: 44},
+		{Pos: 46, Op: querylang.OpEq, Value: "quarterly report", ValuePos: 46, Quoted: true},
+		{Pos: 65, Op: querylang.OpEq, Value: "draft*", ValuePos: 65},
+	}, terms)
+}
+
+func TestParseEmpty(t *testing.T) {
+	terms, err := querylang.Parse("   ")
+
+	assert.NoError(t, err)
+	assert.Empty(t, terms)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{`report "quarterly`, 7, "unterminated phrase"},
+		{`"quarterly"report`, 11, "expected a space after the phrase"},
+		{`due:< overdue`, 5, "missing value for due"},
+		{`work :x`, 5, "missing field name"},
+		{`work ! x`, 5, "nothing to negate"},
+		// positions count characters rather than bytes
+		{`café due:`, 9, "missing value for due"},
+	}
+
+	for _, tt := range tests {
+		_, err := querylang.Parse(tt.query)
+
+		var qerr *querylang.Error
+		if assert.ErrorAs(t, err, &qerr, tt.query) {
+			assert.Equal(t, tt.pos, qerr.Pos, tt.query)
+			assert.Equal(t, tt.msg, qerr.Msg, tt.query)
+		}
+	}
+}
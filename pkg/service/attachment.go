@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+	"github.com/ariefro/simple-to-do-service/pkg/logging"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// sniffLen is how much content http.DetectContentType looks at
+	sniffLen = 512
+	// downloadChunkSize is the size of each chunk sent by DownloadAttachment
+	downloadChunkSize = 64 << 10
+)
+
+const attachmentColumns = "id, todo_id, filename, content_type, size, sha256, created_at"
+
+var errAttachmentsDisabled = status.Error(codes.Unimplemented, "attachments are not enabled")
+
+// sniffContentType detects the content type from the first bytes of the
+// content, falling back to the file extension when the content is not
+// recognized.
+func sniffContentType(head []byte, filename string) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+			return byExt
+		}
+	}
+
+	return contentType
+}
+
+func (s *toDoServiceServer) UploadAttachment(stream grpc.ClientStreamingServer[todo.UploadAttachmentRequest, todo.UploadAttachmentResponse]) error {
+	if s.blobs == nil {
+		return errAttachmentsDisabled
+	}
+
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "attachment info is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	info := first.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "attachment info is required")
+	}
+	if info.GetToDoId() == 0 {
+		return status.Error(codes.InvalidArgument, "todo id is required")
+	}
+	if err := util.ValidateFilename(info.GetFilename()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// fail before receiving the content if the todo does not exist
+	var toDoID int64
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL", info.GetToDoId()).Scan(&toDoID); err != nil {
+		if err == sql.ErrNoRows {
+			return status.Error(codes.NotFound, "todo not found")
+		}
+
+		return status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	// spool the content to disk while hashing it, so memory use does not
+	// depend on the attachment size
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return status.Error(codes.Internal, "failed to create temporary file: "+err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	w := io.MultiWriter(tmp, hash)
+
+	var (
+		size int64
+		head []byte
+	)
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		chunk := req.GetChunk()
+		size += int64(len(chunk))
+		if size > s.maxAttachmentSize {
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("attachment exceeds the %d byte limit", s.maxAttachmentSize))
+		}
+
+		if len(head) < sniffLen {
+			head = append(head, chunk[:min(len(chunk), sniffLen-len(head))]...)
+		}
+
+		if _, err := w.Write(chunk); err != nil {
+			return status.Error(codes.Internal, "failed to buffer attachment: "+err.Error())
+		}
+	}
+
+	if size == 0 {
+		return status.Error(codes.InvalidArgument, "attachment is empty")
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	attachment := &todo.Attachment{
+		ToDoId:      info.GetToDoId(),
+		Filename:    info.GetFilename(),
+		ContentType: sniffContentType(head, info.GetFilename()),
+		Size:        size,
+		Sha256:      sum,
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	// the row is inserted before the blob is stored, so a purge deleting the
+	// same content waits for the upload to commit and then sees the row, or
+	// the upload waits for the purge and stores the blob again
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, "INSERT INTO attachment(`todo_id`, `filename`, `content_type`, `size`, `sha256`, `created_at`) VALUES (?, ?, ?, ?, ?, ?)",
+		attachment.ToDoId, attachment.Filename, attachment.ContentType, attachment.Size, attachment.Sha256, now)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to insert into attachment: "+err.Error())
+	}
+
+	if attachment.Id, err = res.LastInsertId(); err != nil {
+		return status.Error(codes.Internal, "failed to retrieve id for created attachment: "+err.Error())
+	}
+
+	// identical content is stored once, under its hash
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return status.Error(codes.Internal, "failed to read buffered attachment: "+err.Error())
+	}
+	if err := s.blobs.Put(ctx, sum, tmp); err != nil {
+		return status.Error(codes.Internal, "failed to store blob: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	attachment.CreatedAt = timestamppb.New(now)
+
+	return stream.SendAndClose(&todo.UploadAttachmentResponse{
+		Attachment: attachment,
+	})
+}
+
+func (s *toDoServiceServer) DownloadAttachment(req *todo.DownloadAttachmentRequest, stream grpc.ServerStreamingServer[todo.DownloadAttachmentResponse]) error {
+	if s.blobs == nil {
+		return errAttachmentsDisabled
+	}
+
+	if req.GetId() == 0 {
+		return status.Error(codes.InvalidArgument, "attachment id is required")
+	}
+
+	ctx := stream.Context()
+
+	var (
+		a         todo.Attachment
+		createdAt time.Time
+	)
+	err := s.db.QueryRowContext(ctx, "SELECT "+attachmentColumns+" FROM attachment WHERE id = ?", req.GetId()).
+		Scan(&a.Id, &a.ToDoId, &a.Filename, &a.ContentType, &a.Size, &a.Sha256, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return status.Error(codes.NotFound, "attachment not found")
+		}
+
+		return status.Error(codes.Internal, "failed to retrive attachment: "+err.Error())
+	}
+	a.CreatedAt = timestamppb.New(createdAt)
+
+	r, err := s.blobs.Get(ctx, a.Sha256)
+	if err != nil {
+		if err == blob.ErrNotFound {
+			return status.Error(codes.DataLoss, "attachment content is missing")
+		}
+
+		return status.Error(codes.Internal, "failed to open blob: "+err.Error())
+	}
+	defer r.Close()
+
+	if err := stream.Send(&todo.DownloadAttachmentResponse{Data: &todo.DownloadAttachmentResponse_Attachment{Attachment: &a}}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, downloadChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := &todo.DownloadAttachmentResponse{Data: &todo.DownloadAttachmentResponse_Chunk{Chunk: buf[:n]}}
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, "failed to read blob: "+err.Error())
+		}
+	}
+}
+
+// trashedBlobs returns the blobs referenced by attachments of todos trashed
+// before cutoff. The todos and attachments are locked, so the blobs are those
+// of exactly the todos the transaction goes on to purge: a todo cannot be
+// restored, or given another attachment, in between.
+func trashedBlobs(ctx context.Context, tx *sql.Tx, cutoff time.Time) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT a.sha256 FROM attachment a JOIN todo t ON t.id = a.todo_id "+
+		"WHERE t.deleted_at IS NOT NULL AND t.deleted_at <= ? FOR UPDATE", cutoff)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve attachments: "+err.Error())
+	}
+	defer rows.Close()
+
+	var sums []string
+	for rows.Next() {
+		var sum string
+		if err := rows.Scan(&sum); err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan attachment: "+err.Error())
+		}
+		sums = append(sums, sum)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve attachments: "+err.Error())
+	}
+
+	return sums, nil
+}
+
+// deleteOrphanBlobs removes the given blobs once no attachment references
+// them. It is best effort: the todos are already gone, and a blob left
+// behind is only wasted space.
+func deleteOrphanBlobs(ctx context.Context, db *sql.DB, store blob.Store, sums []string) {
+	log := logging.FromContext(ctx)
+	for _, sum := range sums {
+		if err := deleteOrphanBlob(ctx, db, store, sum); err != nil {
+			log.WarnContext(ctx, "failed to delete orphan blob", slog.String("sha256", sum), slog.Any("error", err))
+		}
+	}
+}
+
+// deleteOrphanBlob removes a blob if no attachment references it. The
+// references are counted with a locking read, which waits for uploads of
+// the same content in flight and keeps new ones from inserting their row
+// until the blob is gone.
+func deleteOrphanBlob(ctx context.Context, db *sql.DB, store blob.Store, sum string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var refs int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM attachment WHERE sha256 = ? FOR UPDATE", sum).Scan(&refs); err != nil {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+
+	if err := store.Delete(ctx, sum); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,250 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pngContent starts with the PNG signature so it is sniffed as image/png
+var pngContent = []byte("\x89PNG\r\n\x1a\n rest of the image")
+
+type uploadStream struct {
+	grpc.ServerStream
+	reqs []*todo.UploadAttachmentRequest
+	res  *todo.UploadAttachmentResponse
+}
+
+func (s *uploadStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *uploadStream) Recv() (*todo.UploadAttachmentRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+
+func (s *uploadStream) SendAndClose(res *todo.UploadAttachmentResponse) error {
+	s.res = res
+	return nil
+}
+
+type downloadStream struct {
+	grpc.ServerStream
+	sent []*todo.DownloadAttachmentResponse
+}
+
+func (s *downloadStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *downloadStream) Send(res *todo.DownloadAttachmentResponse) error {
+	s.sent = append(s.sent, res)
+	return nil
+}
+
+func uploadRequests(toDoID int64, filename string, chunks ...[]byte) []*todo.UploadAttachmentRequest {
+	reqs := []*todo.UploadAttachmentRequest{
+		{Data: &todo.UploadAttachmentRequest_Info{Info: &todo.AttachmentInfo{ToDoId: toDoID, Filename: filename}}},
+	}
+	for _, chunk := range chunks {
+		reqs = append(reqs, &todo.UploadAttachmentRequest{Data: &todo.UploadAttachmentRequest_Chunk{Chunk: chunk}})
+	}
+
+	return reqs
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestUploadAttachmentSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	srv := service.NewTodoServiceServer(db, service.WithBlobStore(store, 1024))
+
+	sum := sha256Hex(pngContent)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO attachment").
+		WithArgs(1, "screenshot.bin", "image/png", len(pngContent), sum, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	stream := &uploadStream{reqs: uploadRequests(1, "screenshot.bin", pngContent[:5], pngContent[5:])}
+	err = srv.UploadAttachment(stream)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stream.res.Attachment.Id)
+	assert.Equal(t, "image/png", stream.res.Attachment.ContentType)
+	assert.Equal(t, sum, stream.res.Attachment.Sha256)
+
+	exists, err := store.Exists(context.Background(), sum)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// failingStore fails to store any blob
+type failingStore struct {
+	blob.Store
+}
+
+func (failingStore) Put(ctx context.Context, key string, r io.Reader) error {
+	return errors.New("disk full")
+}
+
+func TestUploadAttachmentStoreFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db, service.WithBlobStore(failingStore{}, 1024))
+
+	// the row is inserted first and goes away with the blob
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO attachment").WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectRollback()
+
+	err = srv.UploadAttachment(&uploadStream{reqs: uploadRequests(1, "screenshot.png", pngContent)})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAttachmentTooLarge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	srv := service.NewTodoServiceServer(db, service.WithBlobStore(store, 8))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	err = srv.UploadAttachment(&uploadStream{reqs: uploadRequests(1, "big.png", pngContent)})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "8 byte limit")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUploadAttachmentDisabled(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	err = srv.UploadAttachment(&uploadStream{reqs: uploadRequests(1, "a.png", pngContent)})
+
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestDownloadAttachment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	sum := sha256Hex(pngContent)
+	require.NoError(t, store.Put(context.Background(), sum, bytes.NewReader(pngContent)))
+
+	srv := service.NewTodoServiceServer(db, service.WithBlobStore(store, 0))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, todo_id, filename, content_type, size, sha256, created_at FROM attachment WHERE id = ?")).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "todo_id", "filename", "content_type", "size", "sha256", "created_at"}).
+			AddRow(4, 1, "screenshot.png", "image/png", len(pngContent), sum, time.Now()))
+
+	stream := &downloadStream{}
+	err = srv.DownloadAttachment(&todo.DownloadAttachmentRequest{Id: 4}, stream)
+
+	require.NoError(t, err)
+	require.Len(t, stream.sent, 2)
+	assert.Equal(t, "screenshot.png", stream.sent[0].GetAttachment().Filename)
+	assert.Equal(t, pngContent, stream.sent[1].GetChunk())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeTrashRemovesOrphanBlobs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := blob.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	orphan := sha256Hex([]byte("only here"))
+	shared := sha256Hex(pngContent)
+	require.NoError(t, store.Put(context.Background(), orphan, bytes.NewReader([]byte("only here"))))
+	require.NoError(t, store.Put(context.Background(), shared, bytes.NewReader(pngContent)))
+
+	srv := service.NewTodoServiceServer(db, service.WithBlobStore(store, 0))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT a.sha256 FROM attachment a JOIN todo t ON t.id = a.todo_id " +
+		"WHERE t.deleted_at IS NOT NULL AND t.deleted_at <= ? FOR UPDATE")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}).AddRow(orphan).AddRow(shared))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE n FROM notification n")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo WHERE deleted_at IS NOT NULL AND deleted_at <= ?")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM attachment WHERE sha256 = ? FOR UPDATE")).WithArgs(orphan).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM attachment WHERE sha256 = ? FOR UPDATE")).WithArgs(shared).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	res, err := srv.PurgeTrash(context.Background(), &todo.PurgeTrashRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res.Purged)
+
+	exists, _ := store.Exists(context.Background(), orphan)
+	assert.False(t, exists)
+	exists, _ = store.Exists(context.Background(), shared)
+	assert.True(t, exists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/audit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditServiceServer is implementation of AuditServiceServer proto interface
+type auditServiceServer struct {
+	todo.UnimplementedAuditServiceServer
+	db *sql.DB
+
+	// admins are the principals allowed to read the audit log
+	admins map[string]bool
+}
+
+// NewAuditServiceServer serves the audit log in db to the given admins.
+func NewAuditServiceServer(db *sql.DB, admins []string) todo.AuditServiceServer {
+	s := &auditServiceServer{
+		db:     db,
+		admins: map[string]bool{},
+	}
+	for _, admin := range admins {
+		s.admins[admin] = true
+	}
+
+	return s
+}
+
+// requireAdmin fails unless the caller is an audit admin. The caller is
+// identified by x-user-id alone, so the audit log is only restricted when an
+// authenticating proxy sets that metadata, see util.PrincipalMetadataKey.
+func (s *auditServiceServer) requireAdmin(ctx context.Context) error {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !s.admins[principal] {
+		return status.Error(codes.PermissionDenied, "the audit log is restricted to audit admins")
+	}
+
+	return nil
+}
+
+// auditFilter converts a request to an audit.Filter, leaving the limit to
+// the caller.
+func auditFilter(req *todo.QueryAuditLogRequest) (audit.Filter, error) {
+	f := audit.Filter{
+		Principal:  req.GetPrincipal(),
+		Method:     req.GetMethod(),
+		ResourceID: req.GetResourceId(),
+		AfterID:    req.GetAfterId(),
+	}
+
+	if req.GetSince() != nil {
+		if err := req.GetSince().CheckValid(); err != nil {
+			return f, status.Error(codes.InvalidArgument, "invalid since: "+err.Error())
+		}
+		f.Since = req.GetSince().AsTime()
+	}
+
+	if req.GetUntil() != nil {
+		if err := req.GetUntil().CheckValid(); err != nil {
+			return f, status.Error(codes.InvalidArgument, "invalid until: "+err.Error())
+		}
+		f.Until = req.GetUntil().AsTime()
+	}
+
+	return f, nil
+}
+
+func auditEntryProto(e audit.Entry) *todo.AuditEntry {
+	return &todo.AuditEntry{
+		Id:          e.ID,
+		Time:        timestamppb.New(e.Time),
+		Principal:   e.Principal,
+		Method:      e.Method,
+		ResourceIds: e.ResourceIDs,
+		Code:        e.Code,
+		ClientIp:    e.ClientIP,
+		Latency:     durationpb.New(time.Duration(e.LatencyUS) * time.Microsecond),
+		PrevHash:    e.PrevHash,
+		Hash:        e.Hash,
+	}
+}
+
+func (s *auditServiceServer) QueryAuditLog(ctx context.Context, req *todo.QueryAuditLogRequest) (*todo.QueryAuditLogResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	f, err := auditFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	f.Limit = int(req.GetLimit())
+	if f.Limit < 0 || f.Limit > maxAuditLimit {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("limit must be between 0 and %d", maxAuditLimit))
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultAuditLimit
+	}
+
+	entries, err := audit.Query(ctx, s.db, f)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve audit log: "+err.Error())
+	}
+
+	res := make([]*todo.AuditEntry, len(entries))
+	for i, e := range entries {
+		res[i] = auditEntryProto(e)
+	}
+
+	return &todo.QueryAuditLogResponse{
+		Entries: res,
+	}, nil
+}
+
+// exportWriter sends every write as one message of an export.
+type exportWriter struct {
+	stream grpc.ServerStreamingServer[todo.ExportAuditLogResponse]
+}
+
+func (w exportWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&todo.ExportAuditLogResponse{Data: p}); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (s *auditServiceServer) ExportAuditLog(req *todo.QueryAuditLogRequest, stream grpc.ServerStreamingServer[todo.ExportAuditLogResponse]) error {
+	ctx := stream.Context()
+	if err := s.requireAdmin(ctx); err != nil {
+		return err
+	}
+
+	f, err := auditFilter(req)
+	if err != nil {
+		return err
+	}
+
+	if err := audit.Export(ctx, s.db, f, exportWriter{stream: stream}); err != nil {
+		return status.Error(codes.Internal, "failed to export audit log: "+err.Error())
+	}
+
+	return nil
+}
+
+func (s *auditServiceServer) VerifyAuditLog(ctx context.Context, req *todo.VerifyAuditLogRequest) (*todo.VerifyAuditLogResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	checked, broken, err := audit.Verify(ctx, s.db)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to verify audit log: "+err.Error())
+	}
+
+	return &todo.VerifyAuditLogResponse{
+		Intact:   broken == 0,
+		Checked:  checked,
+		BrokenId: broken,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQueryAuditLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewAuditServiceServer(db, []string{"alice"})
+	now := time.Now().UTC()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, created_at, principal, method, resource_ids, code, client_ip, latency_us, prev_hash, hash FROM audit_log "+
+		"WHERE id > ? AND principal = ? AND JSON_CONTAINS(resource_ids, ?) ORDER BY id LIMIT ?")).
+		WithArgs(10, "bob", "7", 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "principal", "method", "resource_ids", "code", "client_ip", "latency_us", "prev_hash", "hash"}).
+			AddRow(11, now, "bob", "/pb.ToDoService/Delete", "[7]", "OK", "10.0.0.1", 1500, "a", "b"))
+
+	res, err := srv.QueryAuditLog(asUser("alice"), &todo.QueryAuditLogRequest{Principal: "bob", ResourceId: 7, AfterId: 10})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Entries, 1)
+	assert.Equal(t, []int64{7}, res.Entries[0].ResourceIds)
+	assert.Equal(t, 1500*time.Microsecond, res.Entries[0].Latency.AsDuration())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryAuditLogRequiresAdmin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewAuditServiceServer(db, []string{"alice"})
+
+	_, err = srv.QueryAuditLog(asUser("bob"), &todo.QueryAuditLogRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = srv.VerifyAuditLog(context.Background(), &todo.VerifyAuditLogRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBatchSize is the most items a single batch request may hold
+const maxBatchSize = 500
+
+// runBatch applies n items. By default they share one transaction and the
+// first failure rolls back the whole batch, reported with the position of
+// the failing item. In best-effort mode every item gets its own transaction
+// and the status of each is returned instead.
+func (s *toDoServiceServer) runBatch(ctx context.Context, n int, bestEffort bool, field string, apply func(tx *sql.Tx, i int) error) ([]*spb.Status, error) {
+	if n == 0 {
+		return nil, status.Error(codes.InvalidArgument, field+" cannot be empty")
+	}
+	if n > maxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("a batch cannot hold more than %d items", maxBatchSize))
+	}
+
+	statuses := make([]*spb.Status, n)
+
+	// watchers are woken even when nothing was committed, which is harmless
+	defer s.events.Publish()
+
+	if bestEffort {
+		for i := range n {
+			statuses[i] = statusProto(s.applyInTx(ctx, func(tx *sql.Tx) error { return apply(tx, i) }))
+		}
+
+		return statuses, nil
+	}
+
+	err := s.applyInTx(ctx, func(tx *sql.Tx) error {
+		for i := range n {
+			if err := apply(tx, i); err != nil {
+				st := status.Convert(err)
+				return status.Errorf(st.Code(), "%s[%d]: %s", field, i, st.Message())
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range statuses {
+		statuses[i] = statusProto(nil)
+	}
+
+	return statuses, nil
+}
+
+// statusProto converts an item's error, nil meaning success, to the status
+// reported for it.
+func statusProto(err error) *spb.Status {
+	if err == nil {
+		return status.New(codes.OK, "").Proto()
+	}
+
+	return status.Convert(err).Proto()
+}
+
+// applyInTx runs fn in a transaction, committing when it succeeds.
+func (s *toDoServiceServer) applyInTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		undoLogFrom(ctx).discard()
+		return err
+	}
+
+	if err := flushUndo(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	return nil
+}
+
+func (s *toDoServiceServer) BatchCreate(ctx context.Context, req *todo.BatchCreateRequest) (*todo.BatchCreateResponse, error) {
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, len(req.GetToDos()))
+
+	statuses, err := s.runBatch(ctx, len(req.GetToDos()), req.GetBestEffort(), "to_dos", func(tx *sql.Tx, i int) error {
+		t := req.GetToDos()[i]
+		if err := validateToDo(t); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		id, err := insertToDo(ctx, tx, t, clockAt(time.Now(), toDoFields...))
+		ids[i] = id
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*todo.BatchCreateResult, len(statuses))
+	for i, st := range statuses {
+		results[i] = &todo.BatchCreateResult{Status: st}
+		if st.GetCode() == int32(codes.OK) {
+			results[i].Id = ids[i]
+		}
+	}
+
+	return &todo.BatchCreateResponse{
+		Results:   results,
+		UndoToken: undo.Token(),
+	}, nil
+}
+
+func (s *toDoServiceServer) BatchUpdate(ctx context.Context, req *todo.BatchUpdateRequest) (*todo.BatchUpdateResponse, error) {
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, len(req.GetToDos()))
+
+	statuses, err := s.runBatch(ctx, len(req.GetToDos()), req.GetBestEffort(), "to_dos", func(tx *sql.Tx, i int) error {
+		t := req.GetToDos()[i]
+		if t.GetId() == 0 {
+			return status.Error(codes.InvalidArgument, "todo id is required")
+		}
+		if err := validateToDo(t); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		version, err := updateToDo(ctx, tx, t, clockAt(time.Now(), toDoFields...))
+		versions[i] = version
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*todo.BatchUpdateResult, len(statuses))
+	for i, st := range statuses {
+		results[i] = &todo.BatchUpdateResult{Status: st}
+		if st.GetCode() == int32(codes.OK) {
+			results[i].Version = versions[i]
+		}
+	}
+
+	return &todo.BatchUpdateResponse{
+		Results:   results,
+		UndoToken: undo.Token(),
+	}, nil
+}
+
+func (s *toDoServiceServer) BatchDelete(ctx context.Context, req *todo.BatchDeleteRequest) (*todo.BatchDeleteResponse, error) {
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := s.runBatch(ctx, len(req.GetItems()), req.GetBestEffort(), "items", func(tx *sql.Tx, i int) error {
+		item := req.GetItems()[i]
+		if item.GetId() == 0 {
+			return status.Error(codes.InvalidArgument, "todo id is required")
+		}
+
+		return trashToDo(ctx, tx, item.GetId(), item.GetVersion(), time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*todo.BatchDeleteResult, len(statuses))
+	for i, st := range statuses {
+		results[i] = &todo.BatchDeleteResult{Status: st}
+	}
+
+	return &todo.BatchDeleteResponse{
+		Results:   results,
+		UndoToken: undo.Token(),
+	}, nil
+}
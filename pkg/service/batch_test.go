@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const trashQuery = "UPDATE todo SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+
+func TestBatchCreateAllOrNothing(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	reminder := timestamppb.New(time.Now())
+	req := &todo.BatchCreateRequest{
+		ToDos: []*todo.ToDo{
+			{Title: "first", Description: testDescription, Reminder: reminder},
+			{Title: "second", Description: testDescription, Reminder: reminder},
+		},
+	}
+
+	mock.ExpectBegin()
+	expectLastRank(mock, 0, "")
+	mock.ExpectExec("INSERT INTO todo").WithArgs("first", testDescription, reminder.AsTime(), nil, false, 0, nil, 0, "V").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "created")
+	expectLastRank(mock, 0, "V")
+	mock.ExpectExec("INSERT INTO todo").WithArgs("second", testDescription, reminder.AsTime(), nil, false, 0, nil, 0, "l").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	expectStampFields(mock, 2, toDoFields...)
+	expectRecordChange(mock, 2, "created")
+	expectUndoEntry(mock, 1)
+	expectUndoEntry(mock, 2)
+	mock.ExpectCommit()
+
+	res, err := srv.BatchCreate(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Results, 2)
+	assert.Equal(t, int64(1), res.Results[0].Id)
+	assert.Equal(t, int64(2), res.Results[1].Id)
+	assert.Equal(t, int32(codes.OK), res.Results[1].Status.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchCreateAllOrNothingRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	reminder := timestamppb.New(time.Now())
+	req := &todo.BatchCreateRequest{
+		ToDos: []*todo.ToDo{
+			{Title: "first", Description: testDescription, Reminder: reminder},
+			{Title: "", Description: testDescription, Reminder: reminder},
+		},
+	}
+
+	mock.ExpectBegin()
+	expectLastRank(mock, 0, "")
+	mock.ExpectExec("INSERT INTO todo").WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "created")
+	mock.ExpectRollback()
+
+	res, err := srv.BatchCreate(context.Background(), req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "to_dos[1]: title cannot be empty")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchDeleteBestEffort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	req := &todo.BatchDeleteRequest{
+		Items:      []*todo.DeleteRequest{{Id: 1}, {Id: 2}, {Id: 0}},
+		BestEffort: true,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(trashQuery)).WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, 1, "deleted")
+	expectRecordChange(mock, 1, "deleted")
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(trashQuery)).WithArgs(sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	res, err := srv.BatchDelete(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Results, 3)
+	assert.Equal(t, int32(codes.OK), res.Results[0].Status.Code)
+	assert.Equal(t, int32(codes.NotFound), res.Results[1].Status.Code)
+	assert.Equal(t, int32(codes.InvalidArgument), res.Results[2].Status.Code)
+	assert.NotEmpty(t, res.UndoToken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBatchUpdateEmpty(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	res, err := srv.BatchUpdate(context.Background(), &todo.BatchUpdateRequest{})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
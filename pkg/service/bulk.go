@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	defaultBulkSampleSize = 10
+	maxBulkSampleSize     = 100
+	// maxBulkUpdateSize is the most todos a single BulkUpdate may change;
+	// they are all locked and changed in one transaction
+	maxBulkUpdateSize = 1000
+)
+
+// bulkPatchable lists the fields BulkUpdate may change. Moving todos between
+// lists needs new rank keys and goes through MoveToDo instead.
+var bulkPatchable = map[string]bool{
+	"title":               true,
+	"description":         true,
+	"reminder":            true,
+	"due_at":              true,
+	"due_date_only":       true,
+	"priority":            true,
+	"reminder_before_due": true,
+}
+
+// applyPatch copies the fields named in paths from patch to t, clearing those
+// unset in patch.
+func applyPatch(t, patch *todo.ToDo, paths []string) {
+	dst, src := t.ProtoReflect(), patch.ProtoReflect()
+	for _, path := range paths {
+		fd := dst.Descriptor().Fields().ByName(protoreflect.Name(path))
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		} else {
+			dst.Clear(fd)
+		}
+	}
+}
+
+func (s *toDoServiceServer) BulkUpdate(ctx context.Context, req *todo.BulkUpdateRequest) (*todo.BulkUpdateResponse, error) {
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "update_mask cannot be empty")
+	}
+	for _, path := range paths {
+		if !bulkPatchable[path] {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("field %q cannot be bulk updated", path))
+		}
+	}
+
+	sampleSize := int(req.GetSampleSize())
+	if sampleSize < 0 || sampleSize > maxBulkSampleSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("sample_size must be between 0 and %d", maxBulkSampleSize))
+	}
+	if sampleSize == 0 {
+		sampleSize = defaultBulkSampleSize
+	}
+
+	now := time.Now()
+
+	var where whereClause
+	where.add("deleted_at IS NULL")
+	applyFilter(&where, req.GetFilter(), now)
+	if _, err := applyQuery(&where, s.fullText, req.GetQuery(), now); err != nil {
+		return nil, err
+	}
+
+	if req.GetDryRun() {
+		return s.bulkUpdateDryRun(ctx, where, req.GetPatch(), paths, sampleSize, now)
+	}
+
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+toDoColumns+" FROM todo"+where.String()+" ORDER BY id LIMIT ? FOR UPDATE",
+		append(where.args, maxBulkUpdateSize+1)...)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+
+	var matched []*todo.ToDo
+	for rows.Next() {
+		t, err := scanToDo(rows, now)
+		if err != nil {
+			rows.Close()
+			return nil, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+
+		matched = append(matched, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+	if len(matched) > maxBulkUpdateSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("the filter matches more than %d todos; narrow it down", maxBulkUpdateSize))
+	}
+
+	var sample []*todo.ToDo
+	for _, t := range matched {
+		applyPatch(t, req.GetPatch(), paths)
+		if err := validateToDo(t); err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("todo %d: %v", t.GetId(), err))
+		}
+
+		version, err := updateToDo(ctx, tx, t, clockAt(now, paths...))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sample) < sampleSize {
+			t.Version = version
+			t.Overdue = t.GetDueAt() != nil && isOverdue(t.GetDueAt().AsTime(), t.GetDueDateOnly(), now)
+			sample = append(sample, t)
+		}
+	}
+
+	if err := flushUndo(ctx, tx); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	return &todo.BulkUpdateResponse{
+		Affected:  int64(len(matched)),
+		Sample:    sample,
+		UndoToken: undo.Token(),
+	}, nil
+}
+
+// bulkUpdateDryRun counts the todos a BulkUpdate would change and patches a
+// sample of them in memory. It neither locks nor writes anything, so only the
+// sampled todos are checked against the patch.
+func (s *toDoServiceServer) bulkUpdateDryRun(ctx context.Context, where whereClause, patch *todo.ToDo, paths []string,
+	sampleSize int, now time.Time) (*todo.BulkUpdateResponse, error) {
+	var affected int64
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM todo"+where.String(), where.args...).Scan(&affected); err != nil {
+		return nil, status.Error(codes.Internal, "failed to count todos: "+err.Error())
+	}
+	if affected > maxBulkUpdateSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("the filter matches more than %d todos; narrow it down", maxBulkUpdateSize))
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+toDoColumns+" FROM todo"+where.String()+" ORDER BY id LIMIT ?",
+		append(where.args, sampleSize)...)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+	defer rows.Close()
+
+	var sample []*todo.ToDo
+	for rows.Next() {
+		t, err := scanToDo(rows, now)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+
+		applyPatch(t, patch, paths)
+		if err := validateToDo(t); err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("todo %d: %v", t.GetId(), err))
+		}
+
+		t.Version++
+		t.Overdue = t.GetDueAt() != nil && isOverdue(t.GetDueAt().AsTime(), t.GetDueDateOnly(), now)
+		sample = append(sample, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+
+	return &todo.BulkUpdateResponse{
+		Affected: affected,
+		Sample:   sample,
+	}, nil
+}
@@ -0,0 +1,161 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const bulkSelectQuery = "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND list_id = ? ORDER BY id LIMIT ? FOR UPDATE"
+
+func expectBulkRows(mock sqlmock.Sqlmock, reminder time.Time) {
+	mock.ExpectQuery(regexp.QuoteMeta(bulkSelectQuery)).WithArgs(3, 1001).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "first", testDescription, reminder, nil, false, 0, nil, 3, "V", nil, 1, 0, 0, false, nil).
+			AddRow(2, "second", testDescription, reminder, nil, false, 4, nil, 3, "l", nil, 5, 0, 0, false, nil))
+}
+
+func expectBulkUpdate(mock sqlmock.Sqlmock, id int64, title string, reminder time.Time, version, newVersion int64) {
+	expectPreviousReminder(mock, id, reminder)
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery+" AND version = ?")).
+		WithArgs(title, testDescription, reminder, nil, false, 1, nil, id, version).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(newVersion))
+	expectStampFields(mock, id, "priority")
+	expectRecordChange(mock, id, "updated")
+}
+
+func bulkRequest(dryRun bool) *todo.BulkUpdateRequest {
+	return &todo.BulkUpdateRequest{
+		Filter:     &todo.ToDoFilter{ListId: proto.Int64(3)},
+		Patch:      &todo.ToDo{Priority: todo.Priority_PRIORITY_P1},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"priority"}},
+		DryRun:     dryRun,
+	}
+}
+
+func TestBulkUpdate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	reminder := time.Now().UTC()
+
+	mock.ExpectBegin()
+	expectBulkRows(mock, reminder)
+	expectBulkUpdate(mock, 1, "first", reminder, 1, 2)
+	expectBulkUpdate(mock, 2, "second", reminder, 5, 6)
+	expectUndoEntry(mock, 1)
+	expectUndoEntry(mock, 2)
+	mock.ExpectCommit()
+
+	res, err := srv.BulkUpdate(context.Background(), bulkRequest(false))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.Affected)
+	assert.Len(t, res.Sample, 2)
+	assert.Equal(t, todo.Priority_PRIORITY_P1, res.Sample[1].Priority)
+	assert.Equal(t, int64(6), res.Sample[1].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkUpdateDryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	reminder := time.Now().UTC()
+
+	req := bulkRequest(true)
+	req.SampleSize = 1
+
+	// reads only: no transaction, locks, updates or undo entries
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM todo WHERE deleted_at IS NULL AND list_id = ?")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+toDoColumns+" FROM todo WHERE deleted_at IS NULL AND list_id = ? ORDER BY id LIMIT ?")).
+		WithArgs(3, 1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "first", testDescription, reminder, nil, false, 0, nil, 3, "V", nil, 1, 0, 0, false, nil))
+
+	res, err := srv.BulkUpdate(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), res.Affected)
+	assert.Empty(t, res.UndoToken)
+	if assert.Len(t, res.Sample, 1) {
+		assert.Equal(t, int64(1), res.Sample[0].Id)
+		assert.Equal(t, todo.Priority_PRIORITY_P1, res.Sample[0].Priority)
+		assert.Equal(t, int64(2), res.Sample[0].Version)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkUpdateTooManyMatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	rows := sqlmock.NewRows(toDoRowColumns)
+	for id := 1; id <= 1001; id++ {
+		rows.AddRow(id, "todo", testDescription, time.Now(), nil, false, 0, nil, 3, "V", nil, 1, 0, 0, false, nil)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(bulkSelectQuery)).WithArgs(3, 1001).WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	res, err := srv.BulkUpdate(context.Background(), bulkRequest(false))
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "more than 1000 todos")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkUpdateDryRunTooManyMatches(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM todo WHERE deleted_at IS NULL AND list_id = ?")).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1001))
+
+	res, err := srv.BulkUpdate(context.Background(), bulkRequest(true))
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBulkUpdateRejectsUnpatchableField(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	req := bulkRequest(false)
+	req.UpdateMask.Paths = []string{"list_id"}
+
+	res, err := srv.BulkUpdate(context.Background(), req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// lockToDo checks that a todo exists and locks its row for the rest of the
+// transaction, serializing changes to the todo's children.
+func lockToDo(ctx context.Context, tx *sql.Tx, id int64) error {
+	var lockedID int64
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id).Scan(&lockedID); err != nil {
+		if err == sql.ErrNoRows {
+			return status.Error(codes.NotFound, "todo not found")
+		}
+
+		return status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	return nil
+}
+
+// lockChecklistItem locks a checklist item and its todo for the rest of the
+// transaction and returns the id of the todo. The todo is locked first, as
+// by the changes to the whole checklist, and the items of a trashed todo are
+// not found.
+func lockChecklistItem(ctx context.Context, tx *sql.Tx, id int64) (int64, error) {
+	var toDoID int64
+	if err := tx.QueryRowContext(ctx, "SELECT todo_id FROM checklist_item WHERE id = ?", id).Scan(&toDoID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, status.Error(codes.NotFound, "checklist item not found")
+		}
+
+		return 0, status.Error(codes.Internal, "failed to retrive checklist item: "+err.Error())
+	}
+
+	if err := lockToDo(ctx, tx, toDoID); err != nil {
+		return 0, err
+	}
+
+	// items never move between todos, but this one may have been removed
+	// while waiting for the todo
+	if err := tx.QueryRowContext(ctx, "SELECT todo_id FROM checklist_item WHERE id = ? FOR UPDATE", id).Scan(&toDoID); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, status.Error(codes.NotFound, "checklist item not found")
+		}
+
+		return 0, status.Error(codes.Internal, "failed to retrive checklist item: "+err.Error())
+	}
+
+	return toDoID, nil
+}
+
+func (s *toDoServiceServer) listChecklist(ctx context.Context, toDoID int64) ([]*todo.ChecklistItem, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, text, checked, position FROM checklist_item WHERE todo_id = ? ORDER BY position, id", toDoID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve checklist: "+err.Error())
+	}
+	defer rows.Close()
+
+	var items []*todo.ChecklistItem
+	for rows.Next() {
+		item := &todo.ChecklistItem{}
+		if err := rows.Scan(&item.Id, &item.Text, &item.Checked, &item.Position); err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan checklist item: "+err.Error())
+		}
+
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve checklist: "+err.Error())
+	}
+
+	return items, nil
+}
+
+func (s *toDoServiceServer) AddChecklistItem(ctx context.Context, req *todo.AddChecklistItemRequest) (*todo.AddChecklistItemResponse, error) {
+	if req.GetToDoId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	if err := util.ValidateChecklistText(req.GetText()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := lockToDo(ctx, tx, req.GetToDoId()); err != nil {
+		return nil, err
+	}
+
+	// new items go to the end of the checklist
+	var position int32
+	err = tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(position) + 1, 0) FROM checklist_item WHERE todo_id = ?", req.GetToDoId()).
+		Scan(&position)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve checklist position: "+err.Error())
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO checklist_item(`todo_id`, `text`, `checked`, `position`) VALUES (?, ?, ?, ?)",
+		req.GetToDoId(), req.GetText(), false, position)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to insert into checklist_item: "+err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve id for created checklist item: "+err.Error())
+	}
+
+	if err := recordChange(ctx, tx, req.GetToDoId(), event.KindUpdated); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	return &todo.AddChecklistItemResponse{
+		Item: &todo.ChecklistItem{
+			Id:       id,
+			Text:     req.GetText(),
+			Position: position,
+		},
+	}, nil
+}
+
+func (s *toDoServiceServer) ToggleChecklistItem(ctx context.Context, req *todo.ToggleChecklistItemRequest) (*todo.ToggleChecklistItemResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "checklist item id is required")
+	}
+
+	err := s.applyInTx(ctx, func(tx *sql.Tx) error {
+		toDoID, err := lockChecklistItem(ctx, tx, req.GetId())
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE checklist_item SET checked = ? WHERE id = ?", req.GetChecked(), req.GetId()); err != nil {
+			return status.Error(codes.Internal, "failed to update checklist item: "+err.Error())
+		}
+
+		return recordChange(ctx, tx, toDoID, event.KindUpdated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish()
+
+	return &todo.ToggleChecklistItemResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *toDoServiceServer) ReorderChecklist(ctx context.Context, req *todo.ReorderChecklistRequest) (*todo.ReorderChecklistResponse, error) {
+	if req.GetToDoId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := lockToDo(ctx, tx, req.GetToDoId()); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM checklist_item WHERE todo_id = ?", req.GetToDoId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve checklist: "+err.Error())
+	}
+
+	existing := make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, status.Error(codes.Internal, "failed to scan checklist item: "+err.Error())
+		}
+		existing[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve checklist: "+err.Error())
+	}
+
+	// the new order must name every item exactly once
+	if len(req.GetItemIds()) != len(existing) {
+		return nil, status.Error(codes.InvalidArgument, "item_ids must list every checklist item exactly once")
+	}
+	for _, id := range req.GetItemIds() {
+		if !existing[id] {
+			return nil, status.Error(codes.InvalidArgument, "item_ids must list every checklist item exactly once")
+		}
+		delete(existing, id)
+	}
+
+	for position, id := range req.GetItemIds() {
+		if _, err := tx.ExecContext(ctx, "UPDATE checklist_item SET position = ? WHERE id = ?", position, id); err != nil {
+			return nil, status.Error(codes.Internal, "failed to update checklist item: "+err.Error())
+		}
+	}
+
+	if err := recordChange(ctx, tx, req.GetToDoId(), event.KindUpdated); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	return &todo.ReorderChecklistResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *toDoServiceServer) RemoveChecklistItem(ctx context.Context, req *todo.RemoveChecklistItemRequest) (*todo.RemoveChecklistItemResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "checklist item id is required")
+	}
+
+	err := s.applyInTx(ctx, func(tx *sql.Tx) error {
+		toDoID, err := lockChecklistItem(ctx, tx, req.GetId())
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM checklist_item WHERE id = ?", req.GetId()); err != nil {
+			return status.Error(codes.Internal, "failed to delete checklist item: "+err.Error())
+		}
+
+		return recordChange(ctx, tx, toDoID, event.KindUpdated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish()
+
+	return &todo.RemoveChecklistItemResponse{
+		Success: true,
+	}, nil
+}
@@ -0,0 +1,220 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const lockToDoQuery = "SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE"
+const checklistItemToDoQuery = "SELECT todo_id FROM checklist_item WHERE id = ?"
+const lockChecklistItemQuery = "SELECT todo_id FROM checklist_item WHERE id = ? FOR UPDATE"
+
+// expectLockChecklistItem expects an item of todo 1 and the todo to be locked.
+func expectLockChecklistItem(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(checklistItemToDoQuery)).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(lockChecklistItemQuery)).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id"}).AddRow(1))
+}
+
+func TestAddChecklistItemSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(position) + 1, 0) FROM checklist_item WHERE todo_id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO checklist_item").
+		WithArgs(1, "buy milk", false, 2).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.AddChecklistItem(context.Background(), &todo.AddChecklistItemRequest{ToDoId: 1, Text: "buy milk"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), res.Item.Id)
+	assert.Equal(t, int32(2), res.Item.Position)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddChecklistItemToDoNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	res, err := srv.AddChecklistItem(context.Background(), &todo.AddChecklistItemRequest{ToDoId: 1, Text: "buy milk"})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddChecklistItemEmptyText(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	res, err := srv.AddChecklistItem(context.Background(), &todo.AddChecklistItemRequest{ToDoId: 1})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Contains(t, err.Error(), "checklist item text cannot be empty")
+}
+
+func TestToggleChecklistItemNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(checklistItemToDoQuery)).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id"}))
+	mock.ExpectRollback()
+
+	res, err := srv.ToggleChecklistItem(context.Background(), &todo.ToggleChecklistItemRequest{Id: 9, Checked: true})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestToggleChecklistItemSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	expectLockChecklistItem(mock, 9)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE checklist_item SET checked = ? WHERE id = ?")).
+		WithArgs(true, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.ToggleChecklistItem(context.Background(), &todo.ToggleChecklistItemRequest{Id: 9, Checked: true})
+
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestToggleChecklistItemOfTrashedToDo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(checklistItemToDoQuery)).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	res, err := srv.ToggleChecklistItem(context.Background(), &todo.ToggleChecklistItemRequest{Id: 9, Checked: true})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReorderChecklistSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM checklist_item WHERE todo_id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE checklist_item SET position = ? WHERE id = ?")).
+		WithArgs(0, 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE checklist_item SET position = ? WHERE id = ?")).
+		WithArgs(1, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.ReorderChecklist(context.Background(), &todo.ReorderChecklistRequest{ToDoId: 1, ItemIds: []int64{11, 10}})
+
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReorderChecklistIncompleteOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM checklist_item WHERE todo_id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+	mock.ExpectRollback()
+
+	res, err := srv.ReorderChecklist(context.Background(), &todo.ReorderChecklistRequest{ToDoId: 1, ItemIds: []int64{11, 11}})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRemoveChecklistItemSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	expectLockChecklistItem(mock, 10)
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM checklist_item WHERE id = ?")).
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.RemoveChecklistItem(context.Background(), &todo.RemoveChecklistItemRequest{Id: 10})
+
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
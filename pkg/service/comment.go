@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/notify"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// requirePrincipal returns the caller's user id, failing when the caller is
+// anonymous.
+func requirePrincipal(ctx context.Context) (string, error) {
+	principal := util.PrincipalFromContext(ctx)
+	if principal == "" {
+		return "", status.Error(codes.Unauthenticated, util.PrincipalMetadataKey+" metadata is required")
+	}
+
+	return principal, nil
+}
+
+// notifyMentions queues a mention notification for every user mentioned in
+// body except the author and the users in skip.
+func notifyMentions(ctx context.Context, tx *sql.Tx, toDoID int64, author, body string, skip []string, now time.Time) error {
+	skipped := map[string]bool{author: true}
+	for _, name := range skip {
+		skipped[name] = true
+	}
+
+	for _, name := range util.ParseMentions(body) {
+		if skipped[name] {
+			continue
+		}
+
+		err := notify.Enqueue(ctx, tx, notify.Notification{
+			Recipient: name,
+			Kind:      notify.KindMention,
+			ToDoID:    toDoID,
+			Message:   author + " mentioned you: " + body,
+			DeliverAt: now,
+		})
+		if err != nil {
+			return status.Error(codes.Internal, "failed to queue mention notification: "+err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (s *toDoServiceServer) AddComment(ctx context.Context, req *todo.AddCommentRequest) (*todo.AddCommentResponse, error) {
+	author, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetToDoId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	if err := util.ValidateCommentBody(req.GetBody()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := lockToDo(ctx, tx, req.GetToDoId()); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, "INSERT INTO todo_comment(`todo_id`, `author`, `body`, `created_at`, `updated_at`) VALUES (?, ?, ?, ?, ?)",
+		req.GetToDoId(), author, req.GetBody(), now, now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to insert into todo_comment: "+err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve id for created comment: "+err.Error())
+	}
+
+	if err := notifyMentions(ctx, tx, req.GetToDoId(), author, req.GetBody(), nil, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	return &todo.AddCommentResponse{
+		Comment: &todo.Comment{
+			Id:        id,
+			ToDoId:    req.GetToDoId(),
+			Author:    author,
+			Body:      req.GetBody(),
+			CreatedAt: timestamppb.New(now),
+			UpdatedAt: timestamppb.New(now),
+			Mentions:  util.ParseMentions(req.GetBody()),
+		},
+	}, nil
+}
+
+// lockComment loads a comment for modification, checking that the caller
+// wrote it.
+func lockComment(ctx context.Context, tx *sql.Tx, id int64, principal string) (*todo.Comment, error) {
+	var (
+		c                    todo.Comment
+		createdAt, updatedAt time.Time
+	)
+
+	err := tx.QueryRowContext(ctx, "SELECT id, todo_id, author, body, created_at, updated_at FROM todo_comment WHERE id = ? FOR UPDATE", id).
+		Scan(&c.Id, &c.ToDoId, &c.Author, &c.Body, &createdAt, &updatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "comment not found")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to retrive comment: "+err.Error())
+	}
+
+	if c.Author != principal {
+		return nil, status.Error(codes.PermissionDenied, "only the author can change a comment")
+	}
+
+	c.CreatedAt = timestamppb.New(createdAt)
+	c.UpdatedAt = timestamppb.New(updatedAt)
+
+	return &c, nil
+}
+
+func (s *toDoServiceServer) EditComment(ctx context.Context, req *todo.EditCommentRequest) (*todo.EditCommentResponse, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "comment id is required")
+	}
+
+	if err := util.ValidateCommentBody(req.GetBody()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	c, err := lockComment(ctx, tx, req.GetId(), principal)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the previous body in the edit history
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, "INSERT INTO comment_edit(`comment_id`, `body`, `edited_at`) VALUES (?, ?, ?)", c.Id, c.Body, now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to insert into comment_edit: "+err.Error())
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE todo_comment SET body = ?, updated_at = ? WHERE id = ?", req.GetBody(), now, c.Id); err != nil {
+		return nil, status.Error(codes.Internal, "failed to update comment: "+err.Error())
+	}
+
+	// users mentioned before the edit have already been notified
+	if err := notifyMentions(ctx, tx, c.ToDoId, c.Author, req.GetBody(), util.ParseMentions(c.Body), now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	c.Body = req.GetBody()
+	c.UpdatedAt = timestamppb.New(now)
+	c.Mentions = util.ParseMentions(c.Body)
+
+	return &todo.EditCommentResponse{
+		Comment: c,
+	}, nil
+}
+
+func (s *toDoServiceServer) DeleteComment(ctx context.Context, req *todo.DeleteCommentRequest) (*todo.DeleteCommentResponse, error) {
+	principal, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "comment id is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if _, err := lockComment(ctx, tx, req.GetId(), principal); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM todo_comment WHERE id = ?", req.GetId()); err != nil {
+		return nil, status.Error(codes.Internal, "failed to delete comment: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	return &todo.DeleteCommentResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *toDoServiceServer) ListComments(ctx context.Context, req *todo.ListCommentsRequest) (*todo.ListCommentsResponse, error) {
+	if req.GetToDoId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	// an empty list would not tell a todo without comments from a missing one
+	var toDoID int64
+	if err := s.db.QueryRowContext(ctx, "SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL", req.GetToDoId()).Scan(&toDoID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, status.Error(codes.NotFound, "todo not found")
+		}
+
+		return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, todo_id, author, body, created_at, updated_at FROM todo_comment WHERE todo_id = ? ORDER BY created_at, id", req.GetToDoId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve comments: "+err.Error())
+	}
+	defer rows.Close()
+
+	var comments []*todo.Comment
+	byID := make(map[int64]*todo.Comment)
+	for rows.Next() {
+		var (
+			c                    todo.Comment
+			createdAt, updatedAt time.Time
+		)
+		if err := rows.Scan(&c.Id, &c.ToDoId, &c.Author, &c.Body, &createdAt, &updatedAt); err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan comment: "+err.Error())
+		}
+
+		c.CreatedAt = timestamppb.New(createdAt)
+		c.UpdatedAt = timestamppb.New(updatedAt)
+		c.Mentions = util.ParseMentions(c.Body)
+
+		comments = append(comments, &c)
+		byID[c.Id] = &c
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve comments: "+err.Error())
+	}
+
+	if req.GetIncludeEdits() && len(comments) > 0 {
+		if err := s.loadCommentEdits(ctx, req.GetToDoId(), byID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &todo.ListCommentsResponse{
+		Comments: comments,
+	}, nil
+}
+
+func (s *toDoServiceServer) loadCommentEdits(ctx context.Context, toDoID int64, byID map[int64]*todo.Comment) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT e.comment_id, e.body, e.edited_at FROM comment_edit e "+
+		"JOIN todo_comment c ON c.id = e.comment_id WHERE c.todo_id = ? ORDER BY e.edited_at, e.id", toDoID)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to retrieve comment edits: "+err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			commentID int64
+			body      string
+			editedAt  time.Time
+		)
+		if err := rows.Scan(&commentID, &body, &editedAt); err != nil {
+			return status.Error(codes.Internal, "failed to scan comment edit: "+err.Error())
+		}
+
+		if c, ok := byID[commentID]; ok {
+			c.Edits = append(c.Edits, &todo.CommentEdit{Body: body, EditedAt: timestamppb.New(editedAt)})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return status.Error(codes.Internal, "failed to retrieve comment edits: "+err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const toDoExistsQuery = "SELECT id FROM todo WHERE id = ? AND deleted_at IS NULL"
+const lockCommentQuery = "SELECT id, todo_id, author, body, created_at, updated_at FROM todo_comment WHERE id = ? FOR UPDATE"
+
+var commentColumns = []string{"id", "todo_id", "author", "body", "created_at", "updated_at"}
+
+func asUser(name string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", name))
+}
+
+func TestAddCommentNotifiesMentions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	body := "@bob can you check this with @alice?"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO todo_comment").
+		WithArgs(1, "alice", body, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	// the author is not notified of their own mention
+	mock.ExpectExec("INSERT INTO notification").
+		WithArgs("bob", "mention", 1, "alice mentioned you: "+body, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	res, err := srv.AddComment(asUser("alice"), &todo.AddCommentRequest{ToDoId: 1, Body: body})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), res.Comment.Id)
+	assert.Equal(t, "alice", res.Comment.Author)
+	assert.Equal(t, []string{"bob", "alice"}, res.Comment.Mentions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAddCommentAnonymous(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	res, err := srv.AddComment(context.Background(), &todo.AddCommentRequest{ToDoId: 1, Body: "hello"})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestEditCommentKeepsHistory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	created := time.Now().Add(-time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockCommentQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(commentColumns).AddRow(3, 1, "alice", "ask @bob", created, created))
+	mock.ExpectExec("INSERT INTO comment_edit").
+		WithArgs(3, "ask @bob", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo_comment SET body = ?, updated_at = ? WHERE id = ?")).
+		WithArgs("ask @bob and @carol", sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// only the newly mentioned user is notified
+	mock.ExpectExec("INSERT INTO notification").
+		WithArgs("carol", "mention", 1, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	res, err := srv.EditComment(asUser("alice"), &todo.EditCommentRequest{Id: 3, Body: "ask @bob and @carol"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ask @bob and @carol", res.Comment.Body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeleteCommentNotAuthor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockCommentQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(commentColumns).AddRow(3, 1, "alice", "hello", time.Now(), time.Now()))
+	mock.ExpectRollback()
+
+	res, err := srv.DeleteComment(asUser("bob"), &todo.DeleteCommentRequest{Id: 3})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListCommentsWithEdits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	now := time.Now()
+	mock.ExpectQuery(regexp.QuoteMeta(toDoExistsQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, todo_id, author, body, created_at, updated_at FROM todo_comment WHERE todo_id = ? ORDER BY created_at, id")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(commentColumns).
+			AddRow(3, 1, "alice", "second draft", now, now).
+			AddRow(4, 1, "bob", "thanks @alice", now, now))
+	mock.ExpectQuery("SELECT e.comment_id, e.body, e.edited_at FROM comment_edit e").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"comment_id", "body", "edited_at"}).
+			AddRow(3, "first draft", now))
+
+	res, err := srv.ListComments(context.Background(), &todo.ListCommentsRequest{ToDoId: 1, IncludeEdits: true})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Comments, 2)
+	assert.Len(t, res.Comments[0].Edits, 1)
+	assert.Equal(t, "first draft", res.Comments[0].Edits[0].Body)
+	assert.Equal(t, []string{"alice"}, res.Comments[1].Mentions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListCommentsOfTrashedToDo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(toDoExistsQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	res, err := srv.ListComments(context.Background(), &todo.ListCommentsRequest{ToDoId: 1})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
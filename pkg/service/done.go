@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *toDoServiceServer) SetDone(ctx context.Context, req *todo.SetDoneRequest) (*todo.SetDoneResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	err := s.applyInTx(ctx, func(tx *sql.Tx) error {
+		if err := lockToDo(ctx, tx, req.GetId()); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE todo SET done = ?, version = version + 1 WHERE id = ?", req.GetDone(), req.GetId()); err != nil {
+			return status.Error(codes.Internal, "failed to update todo: "+err.Error())
+		}
+
+		return recordChange(ctx, tx, req.GetId(), event.KindUpdated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish()
+
+	return &todo.SetDoneResponse{
+		Success: true,
+	}, nil
+}
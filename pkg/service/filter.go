@@ -0,0 +1,94 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+)
+
+// whereClause collects SQL conditions and their arguments so filters can be
+// composed without string juggling in every handler.
+type whereClause struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *whereClause) add(cond string, args ...interface{}) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+func (w *whereClause) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// applyFilter translates a ToDoFilter into SQL conditions.
+func applyFilter(w *whereClause, filter *todo.ToDoFilter, now time.Time) {
+	if filter == nil {
+		return
+	}
+
+	if filter.ListId != nil {
+		w.add("list_id = ?", filter.GetListId())
+	}
+
+	if len(filter.GetPriorities()) > 0 {
+		placeholders := make([]string, len(filter.GetPriorities()))
+		args := make([]interface{}, len(filter.GetPriorities()))
+		for i, p := range filter.GetPriorities() {
+			placeholders[i] = "?"
+			args[i] = int32(p)
+		}
+		w.add("priority IN ("+strings.Join(placeholders, ", ")+")", args...)
+	}
+
+	if filter.GetDueBefore() != nil {
+		w.add("due_at < ?", filter.GetDueBefore().AsTime())
+	}
+
+	if filter.GetDueAfter() != nil {
+		w.add("due_at >= ?", filter.GetDueAfter().AsTime())
+	}
+
+	if filter.GetOverdueOnly() {
+		w.add(overdueCondition(now))
+	}
+}
+
+// overdueCondition selects the todos past their due date. A date-only todo
+// stays due for the whole of its day in the location of now.
+func overdueCondition(now time.Time) (string, interface{}, interface{}) {
+	return "((due_date_only = FALSE AND due_at < ?) OR (due_date_only = TRUE AND due_at < ?))",
+		now, utcDate(now)
+}
+
+// utcDate returns the date of t in its location as midnight UTC, which is
+// how a date-only due date is stored.
+func utcDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// orderBy returns the ORDER BY clause for a ReadAll request. Todos without a
+// due date or priority always sort last, and the default is the manual order
+// kept by MoveToDo.
+func orderBy(sortBy todo.SortField, descending bool) string {
+	dir := "ASC"
+	if descending {
+		dir = "DESC"
+	}
+
+	switch sortBy {
+	case todo.SortField_SORT_FIELD_DUE_AT:
+		return " ORDER BY due_at IS NULL, due_at " + dir + ", id"
+	case todo.SortField_SORT_FIELD_PRIORITY:
+		return " ORDER BY priority = 0, priority " + dir + ", id"
+	default:
+		return " ORDER BY list_id, rank_key " + dir + ", id"
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import "strings"
+
+// fullText is the native full-text search of a database engine, used by
+// Search and by the words and phrases of queries.
+type fullText interface {
+	// query renders terms as a query of the engine. With all set it
+	// requires the terms that are not negated and excludes the others,
+	// otherwise it matches any of the terms regardless of negation.
+	query(terms []searchTerm, all bool) string
+	// matches returns a condition matching the text of cols against a
+	// query rendered by query, which it takes as its only argument.
+	matches(cols ...string) string
+	// score returns an expression scoring the text of cols against a query
+	// rendered by query, which it takes as its only argument.
+	score(cols ...string) string
+}
+
+// textCondition matches todos on their title and description or on one of
+// their comments. It takes the same query twice.
+func textCondition(ft fullText) string {
+	return "(" + ft.matches("title", "description") + " OR " +
+		"id IN (SELECT todo_id FROM todo_comment WHERE " + ft.matches("body") + "))"
+}
+
+// mysqlFullText searches FULLTEXT indexes with MATCH ... AGAINST in boolean
+// mode. The indexes are created by migration 000012.
+type mysqlFullText struct{}
+
+func (mysqlFullText) query(terms []searchTerm, all bool) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		switch {
+		case len(t.words) > 1:
+			parts[i] = `"` + strings.Join(t.words, " ") + `"`
+		case t.prefix:
+			parts[i] = t.words[0] + "*"
+		default:
+			parts[i] = t.words[0]
+		}
+
+		if all && t.negated {
+			parts[i] = "-" + parts[i]
+		} else if all {
+			parts[i] = "+" + parts[i]
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (mysqlFullText) matches(cols ...string) string {
+	return "MATCH(" + strings.Join(cols, ", ") + ") AGAINST (? IN BOOLEAN MODE)"
+}
+
+func (ft mysqlFullText) score(cols ...string) string {
+	return ft.matches(cols...)
+}
+
+// postgresFullText searches with to_tsvector and to_tsquery in the simple
+// configuration, so words are matched as written like MySQL does. It only
+// renders the text search; the rest of the service speaks MySQL, so it is
+// not selectable from the server's flags yet. GIN indexes on the same
+// to_tsvector expressions take the place of the FULLTEXT ones.
+type postgresFullText struct{}
+
+func (postgresFullText) query(terms []searchTerm, all bool) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		// the words hold only letters and digits, so they need no quoting
+		switch {
+		case len(t.words) > 1:
+			parts[i] = "(" + strings.Join(t.words, " <-> ") + ")"
+		case t.prefix:
+			parts[i] = t.words[0] + ":*"
+		default:
+			parts[i] = t.words[0]
+		}
+
+		if all && t.negated {
+			parts[i] = "!" + parts[i]
+		}
+	}
+
+	if all {
+		return strings.Join(parts, " & ")
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (postgresFullText) vector(cols []string) string {
+	return "to_tsvector('simple', concat_ws(' ', " + strings.Join(cols, ", ") + "))"
+}
+
+func (ft postgresFullText) matches(cols ...string) string {
+	return ft.vector(cols) + " @@ to_tsquery('simple', ?)"
+}
+
+func (ft postgresFullText) score(cols ...string) string {
+	return "ts_rank(" + ft.vector(cols) + ", to_tsquery('simple', ?))"
+}
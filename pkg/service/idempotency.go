@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// idempotentCall tracks a Create made with an idempotency key. Keys are
+// scoped to the caller, so two users cannot collide on the same key.
+type idempotentCall struct {
+	principal   string
+	key         string
+	requestHash string
+
+	// replay is the original response when the key has been seen before
+	replay *todo.CreateToDoResponse
+}
+
+// requestHash fingerprints the payload of a request so a reused key can be
+// told apart from a genuine retry.
+func requestHash(m proto.Message) (string, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// beginIdempotentCall claims a key until expiresAt by inserting it with the
+// request hash and an empty response, which finish fills in. A concurrent
+// call with the same key waits on the insert until this transaction ends, so
+// only one of them runs. When the key is already taken with the same payload
+// the original response is returned in replay; a different payload fails
+// with AlreadyExists.
+func beginIdempotentCall(ctx context.Context, tx *sql.Tx, principal, key string, payload proto.Message, expiresAt time.Time) (*idempotentCall, error) {
+	hash, err := requestHash(payload)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to hash request: "+err.Error())
+	}
+
+	call := &idempotentCall{
+		principal:   principal,
+		key:         key,
+		requestHash: hash,
+	}
+
+	// an expired entry no longer holds the key
+	_, err = tx.ExecContext(ctx, "DELETE FROM idempotency_key WHERE principal = ? AND `key` = ? AND expires_at <= ?", principal, key, time.Now())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to delete expired idempotency key: "+err.Error())
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO idempotency_key(`principal`, `key`, `request_hash`, `response`, `expires_at`) VALUES (?, ?, ?, ?, ?)",
+		principal, key, hash, []byte{}, expiresAt)
+	if err == nil {
+		return call, nil
+	}
+	if !isDuplicateKey(err) {
+		return nil, status.Error(codes.Internal, "failed to insert into idempotency_key: "+err.Error())
+	}
+
+	var (
+		storedHash string
+		response   []byte
+	)
+	err = tx.QueryRowContext(ctx, "SELECT request_hash, response FROM idempotency_key WHERE principal = ? AND `key` = ? FOR UPDATE",
+		principal, key).Scan(&storedHash, &response)
+	if err == sql.ErrNoRows {
+		// the entry expired and was purged since the insert failed
+		return nil, status.Error(codes.Aborted, "idempotency key is in use by another request, try again")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve idempotency key: "+err.Error())
+	}
+
+	if storedHash != hash {
+		return nil, status.Error(codes.AlreadyExists, "idempotency key has already been used with a different request")
+	}
+
+	call.replay = &todo.CreateToDoResponse{}
+	if err := proto.Unmarshal(response, call.replay); err != nil {
+		return nil, status.Error(codes.Internal, "failed to decode stored response: "+err.Error())
+	}
+
+	return call, nil
+}
+
+// finish stores the response of the call under the key it claimed.
+func (c *idempotentCall) finish(ctx context.Context, tx *sql.Tx, response proto.Message) error {
+	b, err := proto.Marshal(response)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to encode response: "+err.Error())
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE idempotency_key SET response = ? WHERE principal = ? AND `key` = ?", b, c.principal, c.key)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to store idempotent response: "+err.Error())
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes the idempotency keys that expired before now
+// and returns how many were deleted.
+func PurgeIdempotencyKeys(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM idempotency_key WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const idempotencyExpireQuery = "DELETE FROM idempotency_key WHERE principal = ? AND `key` = ? AND expires_at <= ?"
+
+const idempotencyClaimQuery = "INSERT INTO idempotency_key(`principal`, `key`, `request_hash`, `response`, `expires_at`) VALUES (?, ?, ?, ?, ?)"
+
+const idempotencyLookupQuery = "SELECT request_hash, response FROM idempotency_key WHERE principal = ? AND `key` = ? FOR UPDATE"
+
+// errDuplicateKey is the error of an insert of a key that is already taken
+var errDuplicateKey = &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'retry-1' for key 'PRIMARY'"}
+
+// expectIdempotencyClaim mocks claiming a key; a nil claimErr means the key
+// was free
+func expectIdempotencyClaim(mock sqlmock.Sqlmock, principal, key, hash string, claimErr error) {
+	mock.ExpectExec(regexp.QuoteMeta(idempotencyExpireQuery)).WithArgs(principal, key, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	claim := mock.ExpectExec(regexp.QuoteMeta(idempotencyClaimQuery)).WithArgs(principal, key, hash, []byte{}, sqlmock.AnyArg())
+	if claimErr != nil {
+		claim.WillReturnError(claimErr)
+	} else {
+		claim.WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+}
+
+func hashToDo(t *testing.T, td *todo.ToDo) string {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(td)
+	assert.NoError(t, err)
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestCreateToDoStoresIdempotencyKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db, service.WithIdempotencyWindow(time.Hour))
+
+	req := &todo.CreateToDoRequest{
+		ToDo: &todo.ToDo{
+			Title:       testTitle,
+			Description: testDescription,
+			Reminder:    timestamppb.New(time.Now()),
+		},
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "alice", "idempotency-key", "retry-1"))
+
+	mock.ExpectBegin()
+	expectIdempotencyClaim(mock, "alice", "retry-1", hashToDo(t, req.ToDo), nil)
+	expectLastRank(mock, 0, "")
+	mock.ExpectExec("INSERT INTO todo").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO notification")).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "created")
+	expectUndoEntry(mock, 1)
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE idempotency_key SET response = ? WHERE principal = ? AND `key` = ?")).
+		WithArgs(sqlmock.AnyArg(), "alice", "retry-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	res, err := srv.Create(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateToDoReplaysIdempotencyKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	req := &todo.CreateToDoRequest{
+		ToDo: &todo.ToDo{
+			Title:       testTitle,
+			Description: testDescription,
+			Reminder:    timestamppb.New(time.Now()),
+		},
+		IdempotencyKey: "retry-1",
+	}
+	original, err := proto.Marshal(&todo.CreateToDoResponse{Id: 42})
+	assert.NoError(t, err)
+
+	// the key is taken by an earlier call, or by a concurrent one the insert
+	// waited for
+	mock.ExpectBegin()
+	expectIdempotencyClaim(mock, "", "retry-1", hashToDo(t, req.ToDo), errDuplicateKey)
+	mock.ExpectQuery(regexp.QuoteMeta(idempotencyLookupQuery)).
+		WithArgs("", "retry-1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "response"}).AddRow(hashToDo(t, req.ToDo), original))
+	mock.ExpectRollback()
+
+	res, err := srv.Create(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), res.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateToDoIdempotencyKeyReused(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	req := &todo.CreateToDoRequest{
+		ToDo: &todo.ToDo{
+			Title:       testTitle,
+			Description: testDescription,
+			Reminder:    timestamppb.New(time.Now()),
+		},
+		IdempotencyKey: "retry-1",
+	}
+	other := hashToDo(t, &todo.ToDo{Title: "Another title"})
+
+	mock.ExpectBegin()
+	expectIdempotencyClaim(mock, "", "retry-1", hashToDo(t, req.ToDo), errDuplicateKey)
+	mock.ExpectQuery(regexp.QuoteMeta(idempotencyLookupQuery)).
+		WithArgs("", "retry-1").
+		WillReturnRows(sqlmock.NewRows([]string{"request_hash", "response"}).AddRow(other, []byte{}))
+	mock.ExpectRollback()
+
+	res, err := srv.Create(context.Background(), req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxLabels = 20
+
+// normalizeLabels validates labels and returns them lower case, sorted and
+// without duplicates.
+func normalizeLabels(labels []string) ([]string, error) {
+	normalized := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if err := util.ValidateLabel(l); err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, strings.ToLower(l))
+	}
+
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > maxLabels {
+		return nil, fmt.Errorf("a todo cannot have more than %d labels", maxLabels)
+	}
+
+	return normalized, nil
+}
+
+func (s *toDoServiceServer) SetLabels(ctx context.Context, req *todo.SetLabelsRequest) (*todo.SetLabelsResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	labels, err := normalizeLabels(req.GetLabels())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.applyInTx(ctx, func(tx *sql.Tx) error {
+		if err := lockToDo(ctx, tx, req.GetId()); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM todo_label WHERE todo_id = ?", req.GetId()); err != nil {
+			return status.Error(codes.Internal, "failed to delete from todo_label: "+err.Error())
+		}
+
+		if len(labels) > 0 {
+			args := make([]interface{}, 0, 2*len(labels))
+			for _, l := range labels {
+				args = append(args, req.GetId(), l)
+			}
+
+			query := "INSERT INTO todo_label(`todo_id`, `label`) VALUES " + strings.Repeat(", (?, ?)", len(labels))[2:]
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return status.Error(codes.Internal, "failed to insert into todo_label: "+err.Error())
+			}
+		}
+
+		return recordChange(ctx, tx, req.GetId(), event.KindUpdated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish()
+
+	return &todo.SetLabelsResponse{
+		Labels: labels,
+	}, nil
+}
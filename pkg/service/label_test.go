@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSetLabelsSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo_label WHERE todo_id = ?")).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_label(`todo_id`, `label`) VALUES (?, ?), (?, ?)")).
+		WithArgs(1, "home", 1, "work").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.SetLabels(context.Background(), &todo.SetLabelsRequest{Id: 1, Labels: []string{"Work", "home", "work"}})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"home", "work"}, res.Labels)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetLabelsClearsLabels(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo_label WHERE todo_id = ?")).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.SetLabels(context.Background(), &todo.SetLabelsRequest{Id: 1})
+
+	assert.NoError(t, err)
+	assert.Empty(t, res.Labels)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetLabelsInvalidLabel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	_, err = srv.SetLabels(context.Background(), &todo.SetLabelsRequest{Id: 1, Labels: []string{"deep work"}})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetDoneSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET done = ?, version = version + 1 WHERE id = ?")).WithArgs(true, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.SetDone(context.Background(), &todo.SetDoneRequest{Id: 1, Done: true})
+
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetDoneTrashedToDo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	_, err = srv.SetDone(context.Background(), &todo.SetDoneRequest{Id: 1, Done: true})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
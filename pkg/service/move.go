@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxRankKeyLength is the rank key length above which a list is rebalanced.
+// Repeated moves into the same gap make keys grow by roughly one character
+// every few moves, so this is reached only occasionally.
+const maxRankKeyLength = 32
+
+// lastRankKey returns the highest rank key in a list, or "" for an empty
+// list. The row is locked so concurrent inserts at the end of the same list
+// are serialized.
+func lastRankKey(ctx context.Context, tx *sql.Tx, listID int64) (string, error) {
+	var rankKey string
+	err := tx.QueryRowContext(ctx, "SELECT rank_key FROM todo WHERE list_id = ? ORDER BY rank_key DESC LIMIT 1 FOR UPDATE", listID).
+		Scan(&rankKey)
+	if err != nil && err != sql.ErrNoRows {
+		return "", status.Error(codes.Internal, "failed to retrieve rank key: "+err.Error())
+	}
+
+	return rankKey, nil
+}
+
+// lockPosition returns the list and rank key of a todo, locking its row.
+// Trashed todos are not found.
+func lockPosition(ctx context.Context, tx *sql.Tx, id int64) (int64, string, error) {
+	var (
+		listID  int64
+		rankKey string
+	)
+
+	err := tx.QueryRowContext(ctx, "SELECT list_id, rank_key FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE", id).Scan(&listID, &rankKey)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, "", status.Errorf(codes.NotFound, "todo %d not found", id)
+		}
+
+		return 0, "", status.Error(codes.Internal, "failed to retrieve todo: "+err.Error())
+	}
+
+	return listID, rankKey, nil
+}
+
+// MoveToDo places a todo directly before or after another todo of the same
+// list. Only the moved row is written; the neighbouring rows are locked so
+// concurrent moves into the same gap are serialized and never produce the
+// same key.
+func (s *toDoServiceServer) MoveToDo(ctx context.Context, req *todo.MoveToDoRequest) (*todo.MoveToDoResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	anchorID := req.GetBeforeId()
+	if req.GetAfterId() != 0 {
+		anchorID = req.GetAfterId()
+	}
+	if anchorID == 0 {
+		return nil, status.Error(codes.InvalidArgument, "before_id or after_id is required")
+	}
+	if anchorID == req.GetId() {
+		return nil, status.Error(codes.InvalidArgument, "a todo cannot be moved relative to itself")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	listID, _, err := lockPosition(ctx, tx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	anchorListID, anchorKey, err := lockPosition(ctx, tx, anchorID)
+	if err != nil {
+		return nil, err
+	}
+	if anchorListID != listID {
+		return nil, status.Error(codes.FailedPrecondition, "todos belong to different lists")
+	}
+
+	// find the neighbour on the other side of the anchor, skipping the moved
+	// todo; trashed todos keep their keys but are not in the way
+	var lower, upper string
+	var neighbourQuery string
+	if req.GetBeforeId() != 0 {
+		upper = anchorKey
+		neighbourQuery = "SELECT rank_key FROM todo WHERE list_id = ? AND deleted_at IS NULL AND rank_key < ? AND id <> ? ORDER BY rank_key DESC LIMIT 1 FOR UPDATE"
+	} else {
+		lower = anchorKey
+		neighbourQuery = "SELECT rank_key FROM todo WHERE list_id = ? AND deleted_at IS NULL AND rank_key > ? AND id <> ? ORDER BY rank_key ASC LIMIT 1 FOR UPDATE"
+	}
+
+	var neighbourKey string
+	err = tx.QueryRowContext(ctx, neighbourQuery, listID, anchorKey, req.GetId()).Scan(&neighbourKey)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, status.Error(codes.Internal, "failed to retrieve rank key: "+err.Error())
+	}
+	if req.GetBeforeId() != 0 {
+		lower = neighbourKey
+	} else {
+		upper = neighbourKey
+	}
+
+	rankKey, err := util.RankBetween(lower, upper)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to compute rank key: "+err.Error())
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE todo SET rank_key = ? WHERE id = ?", rankKey, req.GetId()); err != nil {
+		return nil, status.Error(codes.Internal, "failed to move todo: "+err.Error())
+	}
+
+	if err := recordChange(ctx, tx, req.GetId(), event.KindUpdated); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	if len(rankKey) > maxRankKeyLength {
+		// the move itself has succeeded; a failed rebalance is retried on
+		// the next move that produces a long key
+		if newKey, err := s.rebalanceList(ctx, listID, req.GetId()); err == nil {
+			rankKey = newKey
+			s.events.Publish()
+		}
+	}
+
+	return &todo.MoveToDoResponse{
+		RankKey: rankKey,
+	}, nil
+}
+
+// rebalanceList rewrites the rank keys of a list with short, evenly spaced
+// keys, keeping the current order. Trashed todos keep their keys and are
+// placed among the others by them when restored. It returns the new key of
+// todo id.
+func (s *toDoServiceServer) rebalanceList(ctx context.Context, listID, id int64) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM todo WHERE list_id = ? AND deleted_at IS NULL ORDER BY rank_key, id FOR UPDATE", listID)
+	if err != nil {
+		return "", err
+	}
+
+	var ids []int64
+	for rows.Next() {
+		var rowID int64
+		if err := rows.Scan(&rowID); err != nil {
+			rows.Close()
+			return "", err
+		}
+		ids = append(ids, rowID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	var rankKey string
+	for i, key := range util.EvenRanks(len(ids)) {
+		if _, err := tx.ExecContext(ctx, "UPDATE todo SET rank_key = ? WHERE id = ?", key, ids[i]); err != nil {
+			return "", err
+		}
+		// rank keys are not part of a revision, so unlike recordChange this
+		// skips reading every todo of the list back only to find there is
+		// no revision to record
+		if err := event.Record(ctx, tx, ids[i], event.KindUpdated); err != nil {
+			return "", err
+		}
+		if ids[i] == id {
+			rankKey = key
+		}
+	}
+
+	return rankKey, tx.Commit()
+}
@@ -0,0 +1,145 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const positionQuery = "SELECT list_id, rank_key FROM todo WHERE id = ? AND deleted_at IS NULL FOR UPDATE"
+
+func TestMoveToDoBefore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(7, "z"))
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(7, "l"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT rank_key FROM todo WHERE list_id = ? AND deleted_at IS NULL AND rank_key < ? AND id <> ? ORDER BY rank_key DESC LIMIT 1 FOR UPDATE")).
+		WithArgs(7, "l", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"rank_key"}).AddRow("V"))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET rank_key = ? WHERE id = ?")).
+		WithArgs("d", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 3, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.MoveToDo(context.Background(), &todo.MoveToDoRequest{
+		Id:     3,
+		Anchor: &todo.MoveToDoRequest_BeforeId{BeforeId: 2},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "d", res.RankKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveToDoAfterLast(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(0, "V"))
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(0, "l"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT rank_key FROM todo WHERE list_id = ? AND deleted_at IS NULL AND rank_key > ? AND id <> ? ORDER BY rank_key ASC LIMIT 1 FOR UPDATE")).
+		WithArgs(0, "l", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"rank_key"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET rank_key = ? WHERE id = ?")).
+		WithArgs("t", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectCommit()
+
+	res, err := srv.MoveToDo(context.Background(), &todo.MoveToDoRequest{
+		Id:     1,
+		Anchor: &todo.MoveToDoRequest_AfterId{AfterId: 2},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "t", res.RankKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveToDoDifferentLists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(1, "V"))
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(2, "l"))
+	mock.ExpectRollback()
+
+	res, err := srv.MoveToDo(context.Background(), &todo.MoveToDoRequest{
+		Id:     1,
+		Anchor: &todo.MoveToDoRequest_BeforeId{BeforeId: 2},
+	})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveToDoTrashedAnchor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}).AddRow(1, "V"))
+	mock.ExpectQuery(regexp.QuoteMeta(positionQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"list_id", "rank_key"}))
+	mock.ExpectRollback()
+
+	res, err := srv.MoveToDo(context.Background(), &todo.MoveToDoRequest{
+		Id:     1,
+		Anchor: &todo.MoveToDoRequest_BeforeId{BeforeId: 2},
+	})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveToDoInvalidArgument(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	for _, req := range []*todo.MoveToDoRequest{
+		{Id: 1},
+		{Anchor: &todo.MoveToDoRequest_AfterId{AfterId: 2}},
+		{Id: 1, Anchor: &todo.MoveToDoRequest_AfterId{AfterId: 1}},
+	} {
+		res, err := srv.MoveToDo(context.Background(), req)
+
+		assert.Nil(t, res)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
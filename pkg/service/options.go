@@ -0,0 +1,65 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+)
+
+// DefaultMaxAttachmentSize is the upload limit used when WithBlobStore is
+// given a non-positive size.
+const DefaultMaxAttachmentSize = 25 << 20
+
+// Option configures optional features of the server.
+type Option func(*toDoServiceServer)
+
+// WithBlobStore enables attachments, storing their contents in store and
+// rejecting uploads larger than maxSize bytes.
+func WithBlobStore(store blob.Store, maxSize int64) Option {
+	return func(s *toDoServiceServer) {
+		if maxSize <= 0 {
+			maxSize = DefaultMaxAttachmentSize
+		}
+
+		s.blobs = store
+		s.maxAttachmentSize = maxSize
+	}
+}
+
+// DefaultIdempotencyWindow is how long Create remembers an idempotency key
+// unless configured with WithIdempotencyWindow.
+const DefaultIdempotencyWindow = 24 * time.Hour
+
+// WithIdempotencyWindow sets how long Create remembers an idempotency key.
+// Retries arriving later create a new todo.
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(s *toDoServiceServer) {
+		if window > 0 {
+			s.idempotencyWindow = window
+		}
+	}
+}
+
+// DefaultUndoWindow is how long an undo token stays valid unless configured
+// with WithUndoWindow.
+const DefaultUndoWindow = time.Minute
+
+// WithUndoWindow sets how long the undo token returned by a mutation stays
+// valid.
+func WithUndoWindow(window time.Duration) Option {
+	return func(s *toDoServiceServer) {
+		if window > 0 {
+			s.undoWindow = window
+		}
+	}
+}
+
+// WithPostgresFullText makes Search and the words of queries use the
+// full-text search of PostgreSQL, to_tsvector and to_tsquery, in place of
+// MySQL's FULLTEXT indexes. It is the first piece of running on
+// PostgreSQL: the other queries of the service are still written for MySQL.
+func WithPostgresFullText() Option {
+	return func(s *toDoServiceServer) {
+		s.fullText = postgresFullText{}
+	}
+}
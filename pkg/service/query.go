@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/querylang"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var offsetPattern = regexp.MustCompile(`^([+-]?\d+)([hdw])$`)
+
+// invalidQuery converts a query error into an InvalidArgument status with a
+// QueryError detail.
+func invalidQuery(err error) error {
+	var qerr *querylang.Error
+	if !errors.As(err, &qerr) {
+		return status.Error(codes.InvalidArgument, "query: "+err.Error())
+	}
+
+	st, derr := status.New(codes.InvalidArgument, "query: "+qerr.Error()).
+		WithDetails(&todo.QueryError{Position: int32(qerr.Pos), Message: qerr.Msg})
+	if derr != nil {
+		return status.Error(codes.InvalidArgument, "query: "+qerr.Error())
+	}
+
+	return st.Err()
+}
+
+// applyQuery translates a query of the filter language into SQL conditions
+// and returns its words and phrases, which are matched with textCondition
+// of ft. Dates in the query are in the location of now.
+func applyQuery(w *whereClause, ft fullText, q string, now time.Time) ([]searchTerm, error) {
+	terms, err := querylang.Parse(q)
+	if err != nil {
+		return nil, invalidQuery(err)
+	}
+
+	var text []searchTerm
+	for _, t := range terms {
+		if t.Field == "" && !t.Quoted {
+			switch v := strings.ToLower(t.Value); v {
+			case "overdue", "done":
+				t.Field = v
+			}
+		}
+
+		if t.Field == "" {
+			if st, ok := textTerm(t); ok {
+				text = append(text, st)
+			}
+			continue
+		}
+
+		var cond whereClause
+		if err := fieldCondition(&cond, t, now); err != nil {
+			return nil, invalidQuery(err)
+		}
+
+		c := strings.Join(cond.conds, " AND ")
+		if t.Negated {
+			// a NULL due date would otherwise drop the todo both ways
+			c = "NOT COALESCE(" + c + ", FALSE)"
+		}
+		w.add(c, cond.args...)
+	}
+
+	if len(text) > 0 {
+		if hasRequired(text) {
+			match := ft.query(text, true)
+			w.add(textCondition(ft), match, match)
+		} else {
+			// boolean mode needs a term to include, so exclude any match
+			// of the words instead
+			excluded := ft.query(text, false)
+			w.add("NOT "+textCondition(ft), excluded, excluded)
+		}
+	}
+
+	return text, nil
+}
+
+// textTerm converts a word or phrase of a query into a search term. It
+// reports false when there are no letters or digits to search for.
+func textTerm(t querylang.Term) (searchTerm, bool) {
+	words := searchWords(t.Value)
+	if len(words) == 0 {
+		return searchTerm{}, false
+	}
+
+	return searchTerm{
+		words:   words,
+		prefix:  !t.Quoted && len(words) == 1 && strings.HasSuffix(t.Value, "*"),
+		negated: t.Negated,
+	}, true
+}
+
+func fieldCondition(w *whereClause, t querylang.Term, now time.Time) error {
+	switch t.Field {
+	case "due":
+		return dueCondition(w, t, now)
+	case "priority":
+		p, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(t.Value), "p"))
+		if err != nil || p < 1 || p > 4 {
+			return querylang.Errorf(t.ValuePos, "priority must be between 1 and 4")
+		}
+		w.add("priority <> 0")
+		w.add("priority "+string(t.Op)+" ?", p)
+	case "list":
+		if err := onlyEquals(t); err != nil {
+			return err
+		}
+		id, err := strconv.ParseInt(t.Value, 10, 64)
+		if err != nil {
+			return querylang.Errorf(t.ValuePos, "list must be a list id")
+		}
+		w.add("list_id = ?", id)
+	case "label":
+		if err := onlyEquals(t); err != nil {
+			return err
+		}
+		w.add("id IN (SELECT todo_id FROM todo_label WHERE label = ?)", strings.ToLower(t.Value))
+	case "overdue":
+		w.add(overdueCondition(now))
+	case "done":
+		w.add("done")
+	default:
+		return querylang.Errorf(t.Pos, "unknown field %s", t.Field)
+	}
+
+	return nil
+}
+
+func onlyEquals(t querylang.Term) error {
+	if t.Op != querylang.OpEq {
+		return querylang.Errorf(t.ValuePos-len(t.Op), "%s cannot be compared with %s", t.Field, t.Op)
+	}
+
+	return nil
+}
+
+func dueCondition(w *whereClause, t querylang.Term, now time.Time) error {
+	value := strings.ToLower(t.Value)
+	if value == "none" {
+		if err := onlyEquals(t); err != nil {
+			return err
+		}
+		w.add("due_at IS NULL")
+		return nil
+	}
+
+	// offsets and times compare as instants
+	if m := offsetPattern.FindStringSubmatch(value); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return querylang.Errorf(t.ValuePos, "invalid offset %s", t.Value)
+		}
+
+		unit := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+		at := now.Add(time.Duration(n) * unit)
+		if t.Op == querylang.OpEq {
+			from, to := now, at
+			if to.Before(from) {
+				from, to = to, from
+			}
+			w.add("due_at >= ?", from)
+			w.add("due_at < ?", to)
+			return nil
+		}
+
+		w.add("due_at "+string(t.Op)+" ?", at)
+		return nil
+	}
+
+	if at, err := time.Parse(time.RFC3339, t.Value); err == nil {
+		w.add("due_at "+string(t.Op)+" ?", at)
+		return nil
+	}
+
+	// anything else is a day, running from midnight to midnight
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	var day time.Time
+	switch value {
+	case "today":
+		day = today
+	case "tomorrow":
+		day = today.AddDate(0, 0, 1)
+	case "yesterday":
+		day = today.AddDate(0, 0, -1)
+	default:
+		var err error
+		if day, err = time.ParseInLocation(time.DateOnly, t.Value, now.Location()); err != nil {
+			return querylang.Errorf(t.ValuePos, "invalid due date %s", t.Value)
+		}
+	}
+	next := day.AddDate(0, 0, 1)
+
+	var cond string
+	var bounds []time.Time
+	switch t.Op {
+	case querylang.OpLt:
+		cond, bounds = "due_at < ?", []time.Time{day}
+	case querylang.OpLe:
+		cond, bounds = "due_at < ?", []time.Time{next}
+	case querylang.OpGt:
+		cond, bounds = "due_at >= ?", []time.Time{next}
+	case querylang.OpGe:
+		cond, bounds = "due_at >= ?", []time.Time{day}
+	default:
+		cond, bounds = "due_at >= ? AND due_at < ?", []time.Time{day, next}
+	}
+
+	// a timed todo compares with the day in the caller's time zone, and a
+	// date-only todo, stored as midnight UTC, with the same date in UTC
+	var args []interface{}
+	for _, b := range bounds {
+		args = append(args, b)
+	}
+	for _, b := range bounds {
+		args = append(args, utcDate(b))
+	}
+	w.add("((due_date_only = FALSE AND "+cond+") OR (due_date_only = TRUE AND "+cond+"))", args...)
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+const textCondition = "(MATCH(title, description) AGAINST (? IN BOOLEAN MODE) OR " +
+	"id IN (SELECT todo_id FROM todo_comment WHERE MATCH(body) AGAINST (? IN BOOLEAN MODE)))"
+
+func TestReadAllWithQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND list_id = ? AND " +
+		"((due_date_only = FALSE AND due_at < ?) OR (due_date_only = TRUE AND due_at < ?)) AND " +
+		"priority <> 0 AND priority >= ? AND " +
+		"NOT COALESCE(((due_date_only = FALSE AND due_at < ?) OR (due_date_only = TRUE AND due_at < ?)), FALSE) AND " +
+		textCondition + " ORDER BY list_id, rank_key ASC, id"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(3, sqlmock.AnyArg(), sqlmock.AnyArg(), 2, sqlmock.AnyArg(), sqlmock.AnyArg(), `+"quarterly report" -draft`, `+"quarterly report" -draft`).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "Quarterly report", "Final", time.Now(), nil, false, 2, nil, 3, "V", nil, 1, 0, 0, false, nil))
+
+	listID := int64(3)
+	res, err := svc.ReadAll(context.Background(), &todo.ReadAllToDoRequest{
+		Filter: &todo.ToDoFilter{ListId: &listID},
+		Query:  `due:<=today priority:>=p2 !overdue "Quarterly Report" !draft`,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.ToDo, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadAllWithLabelAndDone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND due_at < ? AND " +
+		"id IN (SELECT todo_id FROM todo_label WHERE label = ?) AND NOT COALESCE(done, FALSE) AND " +
+		"priority <> 0 AND priority >= ? AND " + textCondition + " ORDER BY list_id, rank_key ASC, id"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(sqlmock.AnyArg(), "work", 2, `+"quarterly report"`, `+"quarterly report"`).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "Quarterly report", "Final", time.Now(), nil, false, 2, nil, 3, "V", nil, 1, 0, 0, false, "finance,work"))
+
+	res, err := svc.ReadAll(context.Background(), &todo.ReadAllToDoRequest{
+		Query: `due:<7d label:Work !done priority:>=2 "quarterly report"`,
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, res.ToDo, 1) {
+		assert.Equal(t, []string{"finance", "work"}, res.ToDo[0].Labels)
+		assert.False(t, res.ToDo[0].Done)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadAllWithExcludingQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND due_at IS NULL AND NOT " +
+		textCondition + " ORDER BY list_id, rank_key ASC, id"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("draft wip*", "draft wip*").
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns))
+
+	_, err = svc.ReadAll(context.Background(), &todo.ReadAllToDoRequest{Query: "due:none !draft !wip*"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInvalidQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	tests := []struct {
+		query string
+		pos   int32
+		msg   string
+	}{
+		{"work label:>home", 11, "label cannot be compared with >"},
+		{"work color:red", 5, "unknown field color"},
+		{"priority:5", 9, "priority must be between 1 and 4"},
+		{"list:>2", 5, "list cannot be compared with >"},
+		{"due:soon", 4, "invalid due date soon"},
+	}
+
+	for _, tt := range tests {
+		_, err := svc.ReadAll(context.Background(), &todo.ReadAllToDoRequest{Query: tt.query})
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.InvalidArgument, st.Code(), tt.query)
+		if assert.Len(t, st.Details(), 1, tt.query) {
+			assert.True(t, proto.Equal(&todo.QueryError{Position: tt.pos, Message: tt.msg}, st.Details()[0].(proto.Message)), tt.query)
+		}
+	}
+
+	_, err = svc.BulkUpdate(context.Background(), &todo.BulkUpdateRequest{
+		Query:      "label:<work",
+		Patch:      &todo.ToDo{Priority: todo.Priority_PRIORITY_P1},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"priority"}},
+	})
+	assert.Equal(t, "query: label cannot be compared with < at position 6", status.Convert(err).Message())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
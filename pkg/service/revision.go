@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	revisionCreated  = "created"
+	revisionUpdated  = "updated"
+	revisionDeleted  = "deleted"
+	revisionRestored = "restored"
+)
+
+// revisionFields are the fields of a todo kept in its revisions. Rank keys
+// and checklists change too often to be worth a revision.
+var revisionFields = []string{"title", "description", "reminder", "due_at", "due_date_only", "priority", "reminder_before_due", "list_id", "deleted_at"}
+
+// fieldChange is how a FieldChange is stored in todo_revision.changes.
+type fieldChange struct {
+	Field    string      `json:"field"`
+	OldValue interface{} `json:"old_value"`
+	NewValue interface{} `json:"new_value"`
+}
+
+func (c fieldChange) proto() (*todo.FieldChange, error) {
+	oldValue, err := structpb.NewValue(c.OldValue)
+	if err != nil {
+		return nil, err
+	}
+
+	newValue, err := structpb.NewValue(c.NewValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo.FieldChange{Field: c.Field, OldValue: oldValue, NewValue: newValue}, nil
+}
+
+// snapshot returns the revisioned fields of t in protobuf JSON form.
+func snapshot(t *todo.ToDo) ([]byte, error) {
+	s := &todo.ToDo{}
+	applyPatch(s, t, revisionFields)
+
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(s)
+}
+
+// snapshotValues decodes a snapshot into the JSON values of its fields.
+// Round-tripping through ToDo gives every snapshot the same form, whoever
+// wrote it.
+func snapshotValues(b []byte) (map[string]interface{}, error) {
+	if b == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	t := &todo.ToDo{}
+	if err := protojson.Unmarshal(b, t); err != nil {
+		return nil, err
+	}
+
+	canonical, err := snapshot(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(canonical, &values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// diffSnapshots lists the fields that differ between two snapshots.
+func diffSnapshots(from, to []byte) ([]fieldChange, error) {
+	a, err := snapshotValues(from)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := snapshotValues(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []fieldChange
+	for _, field := range revisionFields {
+		if !reflect.DeepEqual(a[field], b[field]) {
+			changes = append(changes, fieldChange{Field: field, OldValue: a[field], NewValue: b[field]})
+		}
+	}
+
+	return changes, nil
+}
+
+func revisionKind(changes []fieldChange, first bool) string {
+	if first {
+		return revisionCreated
+	}
+
+	for _, c := range changes {
+		if c.Field == "deleted_at" {
+			if c.NewValue == nil {
+				return revisionRestored
+			}
+			return revisionDeleted
+		}
+	}
+
+	return revisionUpdated
+}
+
+// recordRevision records the current state of a todo when it differs from
+// its last revision.
+func recordRevision(ctx context.Context, tx *sql.Tx, id int64) error {
+	now := time.Now()
+
+	t, err := scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ?", id), now)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	current, err := snapshot(t)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to encode revision: "+err.Error())
+	}
+
+	var prev []byte
+	err = tx.QueryRowContext(ctx, "SELECT snapshot FROM todo_revision WHERE todo_id = ? ORDER BY id DESC LIMIT 1", id).Scan(&prev)
+	if err != nil && err != sql.ErrNoRows {
+		return status.Error(codes.Internal, "failed to retrieve revision: "+err.Error())
+	}
+
+	trackUndo(ctx, id, prev, t.GetVersion())
+
+	changes, err := diffSnapshots(prev, current)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to compare revisions: "+err.Error())
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to encode revision: "+err.Error())
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO todo_revision(`todo_id`, `actor`, `kind`, `snapshot`, `changes`, `created_at`) VALUES (?, ?, ?, ?, ?, ?)",
+		id, util.PrincipalFromContext(ctx), revisionKind(changes, prev == nil), current, encoded, now)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to insert into todo_revision: "+err.Error())
+	}
+
+	return nil
+}
+
+// loadRevision returns the todo and snapshot of a revision.
+func loadRevision(ctx context.Context, db rowQueryer, id int64) (int64, []byte, error) {
+	var (
+		toDoID int64
+		snap   []byte
+	)
+
+	err := db.QueryRowContext(ctx, "SELECT todo_id, snapshot FROM todo_revision WHERE id = ?", id).Scan(&toDoID, &snap)
+	if err == sql.ErrNoRows {
+		return 0, nil, status.Error(codes.NotFound, fmt.Sprintf("revision %d not found", id))
+	}
+	if err != nil {
+		return 0, nil, status.Error(codes.Internal, "failed to retrieve revision: "+err.Error())
+	}
+
+	return toDoID, snap, nil
+}
+
+func changesProto(changes []fieldChange) ([]*todo.FieldChange, error) {
+	res := make([]*todo.FieldChange, len(changes))
+	for i, c := range changes {
+		var err error
+		if res[i], err = c.proto(); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func (s *toDoServiceServer) ListRevisions(ctx context.Context, req *todo.ListRevisionsRequest) (*todo.ListRevisionsResponse, error) {
+	if req.GetToDoId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, todo_id, actor, kind, changes, created_at FROM todo_revision WHERE todo_id = ? ORDER BY id", req.GetToDoId())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve revisions: "+err.Error())
+	}
+	defer rows.Close()
+
+	var revisions []*todo.Revision
+	for rows.Next() {
+		var (
+			r         todo.Revision
+			encoded   []byte
+			changes   []fieldChange
+			createdAt time.Time
+		)
+		if err := rows.Scan(&r.Id, &r.ToDoId, &r.Actor, &r.Kind, &encoded, &createdAt); err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan revision: "+err.Error())
+		}
+
+		if err := json.Unmarshal(encoded, &changes); err != nil {
+			return nil, status.Error(codes.Internal, "failed to decode revision: "+err.Error())
+		}
+		if r.Changes, err = changesProto(changes); err != nil {
+			return nil, status.Error(codes.Internal, "failed to decode revision: "+err.Error())
+		}
+		r.CreatedAt = timestamppb.New(createdAt)
+
+		revisions = append(revisions, &r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve revisions: "+err.Error())
+	}
+
+	return &todo.ListRevisionsResponse{
+		Revisions: revisions,
+	}, nil
+}
+
+func (s *toDoServiceServer) GetDiff(ctx context.Context, req *todo.GetDiffRequest) (*todo.GetDiffResponse, error) {
+	if req.GetRevA() == 0 || req.GetRevB() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "rev_a and rev_b are required")
+	}
+
+	toDoA, snapA, err := loadRevision(ctx, s.db, req.GetRevA())
+	if err != nil {
+		return nil, err
+	}
+
+	toDoB, snapB, err := loadRevision(ctx, s.db, req.GetRevB())
+	if err != nil {
+		return nil, err
+	}
+
+	if toDoA != toDoB {
+		return nil, status.Error(codes.InvalidArgument, "rev_a and rev_b belong to different todos")
+	}
+
+	changes, err := diffSnapshots(snapA, snapB)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to compare revisions: "+err.Error())
+	}
+
+	res, err := changesProto(changes)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to decode revision: "+err.Error())
+	}
+
+	return &todo.GetDiffResponse{
+		ToDoId:  toDoA,
+		Changes: res,
+	}, nil
+}
+
+// revertToDo puts the fields, but not the list, of a locked todo back to
+// those of target, moving it in or out of the trash as needed.
+func revertToDo(ctx context.Context, tx *sql.Tx, current, target *todo.ToDo, now time.Time) error {
+	wantDeleted := target.GetDeletedAt() != nil
+	trashed := current.GetDeletedAt() != nil
+
+	if trashed && !wantDeleted {
+		if err := restoreToDo(ctx, tx, current.GetId(), now); err != nil {
+			return err
+		}
+		trashed = false
+	}
+
+	// the fields of a trashed todo are frozen until it is restored
+	if !trashed {
+		applyPatch(current, target, toDoFields)
+		if err := validateToDo(current); err != nil {
+			return status.Error(codes.FailedPrecondition, "todo cannot be reverted: "+err.Error())
+		}
+
+		// the row is locked, so there is no version to check
+		current.Version = 0
+		if _, err := updateToDo(ctx, tx, current, clockAt(now, toDoFields...)); err != nil {
+			return err
+		}
+	}
+
+	if wantDeleted && !trashed {
+		if err := trashToDo(ctx, tx, current.GetId(), 0, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *toDoServiceServer) RevertToRevision(ctx context.Context, req *todo.RevertToRevisionRequest) (*todo.RevertToRevisionResponse, error) {
+	if req.GetRevisionId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision id is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	id, snap, err := loadRevision(ctx, tx, req.GetRevisionId())
+	if err != nil {
+		return nil, err
+	}
+
+	target := &todo.ToDo{}
+	if err := protojson.Unmarshal(snap, target); err != nil {
+		return nil, status.Error(codes.Internal, "failed to decode revision: "+err.Error())
+	}
+
+	now := time.Now()
+	current, err := scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ? FOR UPDATE", id), now)
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "todo not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	if req.GetVersion() != 0 && req.GetVersion() != current.GetVersion() {
+		return nil, status.Errorf(codes.Aborted, "todo has been modified, current version is %d", current.GetVersion())
+	}
+
+	if err := revertToDo(ctx, tx, current, target, now); err != nil {
+		return nil, err
+	}
+
+	reverted, err := scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ?", id), now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	return &todo.RevertToRevisionResponse{
+		ToDo: reverted,
+	}, nil
+}
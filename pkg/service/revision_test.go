@@ -0,0 +1,197 @@
+package service_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const loadRevisionQuery = "SELECT todo_id, snapshot FROM todo_revision WHERE id = ?"
+
+// changedFields matches the changes of a revision by the names of the
+// changed fields
+type changedFields []string
+
+func (f changedFields) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	if !ok {
+		return false
+	}
+
+	var changes []struct {
+		Field string `json:"field"`
+	}
+	if err := json.Unmarshal(b, &changes); err != nil || len(changes) != len(f) {
+		return false
+	}
+
+	for i, c := range changes {
+		if c.Field != f[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestDeleteRecordsRevision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+	reminder := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	deletedAt := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL")).
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, 1, "deleted")
+	mock.ExpectExec(regexp.QuoteMeta(recordChangeQuery)).WithArgs("deleted", sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, reminder, nil, false, 2, nil, 0, "V", deletedAt, 2, 0, 0, false, nil))
+	// written by an older version, with zero values spelled out
+	mock.ExpectQuery(regexp.QuoteMeta(lastRevisionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot"}).
+			AddRow(`{"title": "Dummy title", "description": "This is a test description", "reminder": "2030-01-01T09:00:00Z", ` +
+				`"due_at": null, "due_date_only": false, "priority": "PRIORITY_P2", "list_id": 0, "deleted_at": null}`))
+	mock.ExpectExec(regexp.QuoteMeta(insertRevisionQuery)).
+		WithArgs(1, "alice", "deleted", sqlmock.AnyArg(), changedFields{"deleted_at"}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
+
+	_, err = svc.Delete(asUser("alice"), &todo.DeleteRequest{Id: 1})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, todo_id, actor, kind, changes, created_at FROM todo_revision WHERE todo_id = ? ORDER BY id")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "todo_id", "actor", "kind", "changes", "created_at"}).
+			AddRow(3, 1, "", "created", `[{"field": "title", "old_value": null, "new_value": "Draft"}]`, now).
+			AddRow(5, 1, "alice", "updated", `[{"field": "title", "old_value": "Draft", "new_value": "Final"}]`, now))
+
+	res, err := svc.ListRevisions(context.Background(), &todo.ListRevisionsRequest{ToDoId: 1})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Revisions, 2)
+	assert.Equal(t, "alice", res.Revisions[1].Actor)
+	assert.Equal(t, "title", res.Revisions[1].Changes[0].Field)
+	assert.Equal(t, "Draft", res.Revisions[1].Changes[0].OldValue.GetStringValue())
+	assert.Equal(t, "Final", res.Revisions[1].Changes[0].NewValue.GetStringValue())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetDiff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta(loadRevisionQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "snapshot"}).
+			AddRow(1, `{"title": "Draft", "priority": "PRIORITY_P1", "due_at": "2030-01-01T00:00:00Z"}`))
+	mock.ExpectQuery(regexp.QuoteMeta(loadRevisionQuery)).WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "snapshot"}).
+			AddRow(1, `{"title": "Final", "priority": "PRIORITY_P1"}`))
+	mock.ExpectQuery(regexp.QuoteMeta(loadRevisionQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "snapshot"}).AddRow(1, `{}`))
+	mock.ExpectQuery(regexp.QuoteMeta(loadRevisionQuery)).WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "snapshot"}).AddRow(2, `{}`))
+
+	res, err := svc.GetDiff(context.Background(), &todo.GetDiffRequest{RevA: 3, RevB: 5})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Changes, 2)
+	assert.Equal(t, "title", res.Changes[0].Field)
+	assert.Equal(t, "due_at", res.Changes[1].Field)
+	assert.Equal(t, "2030-01-01T00:00:00Z", res.Changes[1].OldValue.GetStringValue())
+	assert.NotNil(t, res.Changes[1].NewValue.GetNullValue())
+
+	_, err = svc.GetDiff(context.Background(), &todo.GetDiffRequest{RevA: 3, RevB: 9})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevertToRevision(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+	reminder := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(loadRevisionQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "snapshot"}).
+			AddRow(1, `{"title": "Draft", "description": "Old", "reminder": "2030-01-01T09:00:00Z", "list_id": "4"}`))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, reminder, nil, false, 2, nil, 0, "V", nil, 6, 0, 0, false, nil))
+	expectPreviousReminder(mock, 1, reminder)
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+		WithArgs("Draft", "Old", reminder, nil, false, 0, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(7))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "Draft", "Old", reminder, nil, false, 0, nil, 0, "V", nil, 7, 0, 0, false, nil))
+	mock.ExpectCommit()
+
+	res, err := svc.RevertToRevision(context.Background(), &todo.RevertToRevisionRequest{RevisionId: 3, Version: 6})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Draft", res.ToDo.Title)
+	// the list is left alone
+	assert.Equal(t, int64(0), res.ToDo.ListId)
+	assert.Equal(t, int64(7), res.ToDo.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevertToRevisionStale(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(loadRevisionQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "snapshot"}).AddRow(1, `{"title": "Draft"}`))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ? FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, time.Now(), nil, false, 0, nil, 0, "V", nil, 6, 0, 0, false, nil))
+	mock.ExpectRollback()
+
+	_, err = svc.RevertToRevision(context.Background(), &todo.RevertToRevisionRequest{RevisionId: 3, Version: 5})
+
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	// maxCommentHighlights is how many matching comments are highlighted
+	// per todo
+	maxCommentHighlights = 3
+	// snippetLead is how much text, in bytes, is kept before the first match
+	// of a snippet
+	snippetLead = 40
+	// snippetLength is the length of a snippet in bytes, before marking
+	snippetLength = 160
+)
+
+// searchTerm is a word, a prefix or a phrase of a query.
+type searchTerm struct {
+	words   []string
+	prefix  bool
+	negated bool
+}
+
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !isWordChar(r)
+	})
+}
+
+// hasRequired reports whether any of terms is not negated.
+func hasRequired(terms []searchTerm) bool {
+	for _, t := range terms {
+		if !t.negated {
+			return true
+		}
+	}
+
+	return false
+}
+
+// highlighter matches any of the terms of a query that are not negated,
+// case insensitively. It does not check word boundaries; snippet does.
+func highlighter(terms []searchTerm) *regexp.Regexp {
+	var alts []string
+	for _, t := range terms {
+		if t.negated {
+			continue
+		}
+
+		words := make([]string, len(t.words))
+		for j, w := range t.words {
+			words[j] = regexp.QuoteMeta(w)
+		}
+
+		alt := strings.Join(words, `[^\pL\pN]+`)
+		if t.prefix {
+			alt += `[\pL\pN]*`
+		}
+		alts = append(alts, alt)
+	}
+
+	return regexp.MustCompile(`(?i)` + strings.Join(alts, "|"))
+}
+
+func isWordChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wholeWords reports whether text[start:end] neither begins nor ends inside
+// a word.
+func wholeWords(text string, start, end int) bool {
+	if r, _ := utf8.DecodeLastRuneInString(text[:start]); start > 0 && isWordChar(r) {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(text[end:]); end < len(text) && isWordChar(r) {
+		return false
+	}
+
+	return true
+}
+
+// snippet returns an excerpt of text around its first match with every match
+// marked, and false when nothing in text matches. The text is HTML-escaped,
+// so the <mark> tags are the only markup in a snippet.
+func snippet(text string, re *regexp.Regexp) (string, bool) {
+	var matches [][]int
+	for _, m := range re.FindAllStringIndex(text, -1) {
+		if wholeWords(text, m[0], m[1]) {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	if first := matches[0][0]; first > snippetLead {
+		start = first - snippetLead
+		// start at a word rather than in the middle of one
+		if i := strings.IndexByte(text[start:first], ' '); i >= 0 {
+			start += i + 1
+		}
+	}
+	if end-start > snippetLength {
+		end = max(start+snippetLength, matches[0][1])
+		if i := strings.LastIndexByte(text[matches[0][1]:end], ' '); i >= 0 {
+			end = matches[0][1] + i
+		}
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < start || m[1] > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
+
+// scoredRow scans a row selected with toDoColumns followed by a score.
+type scoredRow struct {
+	rows  *sql.Rows
+	score *float64
+}
+
+func (r scoredRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.score)...)
+}
+
+func (s *toDoServiceServer) Search(ctx context.Context, req *todo.SearchRequest) (*todo.SearchResponse, error) {
+	limit := int(req.GetLimit())
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	now := time.Now()
+
+	var where whereClause
+	where.add("deleted_at IS NULL")
+	terms, err := applyQuery(&where, s.fullText, req.GetQuery(), now)
+	if err != nil {
+		return nil, err
+	}
+	if !hasRequired(terms) {
+		return nil, status.Error(codes.InvalidArgument, "query: query has no words to search for")
+	}
+	applyFilter(&where, req.GetFilter(), now)
+
+	match := s.fullText.query(terms, true)
+
+	// a todo ranks by its own relevance plus that of its best comment
+	query := "SELECT " + toDoColumns + ", " + s.fullText.score("title", "description") + " + " +
+		"COALESCE((SELECT MAX(" + s.fullText.score("m.body") + ") FROM todo_comment m WHERE m.todo_id = todo.id), 0) AS score " +
+		"FROM todo" + where.String() + " ORDER BY score DESC, id LIMIT ?"
+	args := append([]interface{}{match, match}, where.args...)
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to search todos: "+err.Error())
+	}
+	defer rows.Close()
+
+	re := highlighter(terms)
+	res := &todo.SearchResponse{}
+	for rows.Next() {
+		var score float64
+		t, err := scanToDo(scoredRow{rows: rows, score: &score}, now)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+
+		result := &todo.SearchResult{ToDo: t, Score: score}
+		if text, ok := snippet(t.GetTitle(), re); ok {
+			result.Highlights = append(result.Highlights, &todo.SearchHighlight{Field: "title", Snippet: text})
+		}
+		if text, ok := snippet(t.GetDescription(), re); ok {
+			result.Highlights = append(result.Highlights, &todo.SearchHighlight{Field: "description", Snippet: text})
+		}
+
+		res.Results = append(res.Results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to search todos: "+err.Error())
+	}
+
+	if err := highlightComments(ctx, s.db, s.fullText, match, re, res.Results); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// highlightComments adds the matching comments of the todos in results to
+// their highlights.
+func highlightComments(ctx context.Context, db queryer, ft fullText, match string, re *regexp.Regexp, results []*todo.SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*todo.SearchResult, len(results))
+	placeholders := make([]string, len(results))
+	args := []interface{}{match}
+	for i, result := range results {
+		byID[result.GetToDo().GetId()] = result
+		placeholders[i] = "?"
+		args = append(args, result.GetToDo().GetId())
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT id, todo_id, body FROM todo_comment WHERE "+ft.matches("body")+" "+
+		"AND todo_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY id", args...)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to search comments: "+err.Error())
+	}
+	defer rows.Close()
+
+	highlighted := make(map[int64]int)
+	for rows.Next() {
+		var (
+			id, toDoID int64
+			body       string
+		)
+		if err := rows.Scan(&id, &toDoID, &body); err != nil {
+			return status.Error(codes.Internal, "failed to scan comment: "+err.Error())
+		}
+
+		if highlighted[toDoID] == maxCommentHighlights {
+			continue
+		}
+		// the index may match words that the highlighter does not, such as
+		// other forms of a word, which leaves nothing to show
+		if text, ok := snippet(body, re); ok {
+			result := byID[toDoID]
+			result.Highlights = append(result.Highlights, &todo.SearchHighlight{Field: "comment", Snippet: text, CommentId: id})
+			highlighted[toDoID]++
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return status.Error(codes.Internal, "failed to search comments: "+err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const searchQuery = "SELECT " + toDoColumns + ", MATCH(title, description) AGAINST (? IN BOOLEAN MODE) + " +
+	"COALESCE((SELECT MAX(MATCH(m.body) AGAINST (? IN BOOLEAN MODE)) FROM todo_comment m WHERE m.todo_id = todo.id), 0) AS score " +
+	"FROM todo WHERE deleted_at IS NULL AND (MATCH(title, description) AGAINST (? IN BOOLEAN MODE) OR " +
+	"id IN (SELECT todo_id FROM todo_comment WHERE MATCH(body) AGAINST (? IN BOOLEAN MODE)))"
+
+const searchCommentsQuery = "SELECT id, todo_id, body FROM todo_comment WHERE MATCH(body) AGAINST (? IN BOOLEAN MODE) AND todo_id IN (?, ?) ORDER BY id"
+
+func TestSearch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now()
+	match := `+"quarterly report" +draft*`
+
+	mock.ExpectQuery(regexp.QuoteMeta(searchQuery+" ORDER BY score DESC, id LIMIT ?")).
+		WithArgs(match, match, match, match, 20).
+		WillReturnRows(sqlmock.NewRows(append(toDoRowColumns, "score")).
+			AddRow(1, "Drafting the Quarterly Report", "Send it to finance", now, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil, 2.5).
+			AddRow(2, "Finance", "Numbers", now, nil, false, 0, nil, 0, "l", nil, 1, 0, 0, false, nil, 0.8))
+	mock.ExpectQuery(regexp.QuoteMeta(searchCommentsQuery)).WithArgs(match, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "todo_id", "body"}).
+			AddRow(7, 2, "The draft of the quarterly-report is attached <img src=x onerror=alert(1)>"))
+
+	res, err := srv.Search(context.Background(), &todo.SearchRequest{Query: `"Quarterly Report" draft*`})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Results, 2)
+	assert.Equal(t, 2.5, res.Results[0].Score)
+	assert.Equal(t, []*todo.SearchHighlight{
+		{Field: "title", Snippet: "<mark>Drafting</mark> the <mark>Quarterly Report</mark>"},
+	}, res.Results[0].Highlights)
+	assert.Equal(t, []*todo.SearchHighlight{
+		{Field: "comment", Snippet: "The <mark>draft</mark> of the <mark>quarterly-report</mark> is attached &lt;img src=x onerror=alert(1)&gt;", CommentId: 7},
+	}, res.Results[1].Highlights)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchFilterAndLimit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	listID := int64(3)
+	description := "Lorem ipsum dolor sit amet, consectetur adipiscing elit, sed do eiusmod tempor incididunt ut labore " +
+		"et dolore magna aliqua. Ut enim ad minim veniam, quis nostrud exercitation ullamco laboris nisi ut aliquip ex " +
+		"ea commodo consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore eu fugiat."
+
+	mock.ExpectQuery(regexp.QuoteMeta(searchQuery+" AND list_id = ? ORDER BY score DESC, id LIMIT ?")).
+		WithArgs("+laboris", "+laboris", "+laboris", "+laboris", listID, 100).
+		WillReturnRows(sqlmock.NewRows(append(toDoRowColumns, "score")).
+			AddRow(1, testTitle, description, time.Now(), nil, false, 0, nil, 3, "V", nil, 1, 0, 0, false, nil, 1.0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, todo_id, body FROM todo_comment")).WithArgs("+laboris", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "todo_id", "body"}))
+
+	res, err := srv.Search(context.Background(), &todo.SearchRequest{
+		Query:  "laboris",
+		Filter: &todo.ToDoFilter{ListId: &listID},
+		Limit:  1000,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Results, 1)
+	assert.Equal(t, "…quis nostrud exercitation ullamco <mark>laboris</mark> nisi ut aliquip ex ea commodo "+
+		"consequat. Duis aute irure dolor in reprehenderit in voluptate velit esse cillum dolore…",
+		res.Results[0].Highlights[0].Snippet)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchInvalidQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	for _, query := range []string{"", "  -- ", `report "quarterly`} {
+		_, err := srv.Search(context.Background(), &todo.SearchRequest{Query: query})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), query)
+	}
+
+	_, err = srv.Search(context.Background(), &todo.SearchRequest{Query: `report "quarterly`})
+	assert.Equal(t, "query: unterminated phrase at position 7", status.Convert(err).Message())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchPostgres(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db, service.WithPostgresFullText())
+	match := "(quarterly <-> report) & draft:* & !memo"
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+toDoColumns+", ts_rank(to_tsvector('simple', concat_ws(' ', title, description)), to_tsquery('simple', ?)) + "+
+		"COALESCE((SELECT MAX(ts_rank(to_tsvector('simple', concat_ws(' ', m.body)), to_tsquery('simple', ?))) FROM todo_comment m WHERE m.todo_id = todo.id), 0) AS score "+
+		"FROM todo WHERE deleted_at IS NULL AND (to_tsvector('simple', concat_ws(' ', title, description)) @@ to_tsquery('simple', ?) OR "+
+		"id IN (SELECT todo_id FROM todo_comment WHERE to_tsvector('simple', concat_ws(' ', body)) @@ to_tsquery('simple', ?))) ORDER BY score DESC, id LIMIT ?")).
+		WithArgs(match, match, match, match, 20).
+		WillReturnRows(sqlmock.NewRows(append(toDoRowColumns, "score")).
+			AddRow(1, "Draft the quarterly report", "", time.Now(), nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil, 0.6))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, todo_id, body FROM todo_comment WHERE to_tsvector('simple', concat_ws(' ', body)) @@ to_tsquery('simple', ?) AND todo_id IN (?) ORDER BY id")).
+		WithArgs(match, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "todo_id", "body"}))
+
+	res, err := srv.Search(context.Background(), &todo.SearchRequest{Query: `"Quarterly Report" draft* !memo`})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Results, 1)
+	assert.Equal(t, []*todo.SearchHighlight{
+		{Field: "title", Snippet: "<mark>Draft</mark> the <mark>quarterly report</mark>"},
+	}, res.Results[0].Highlights)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadAllPostgresExcludesWords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db, service.WithPostgresFullText())
+
+	// with no word required, any match of the words is excluded
+	mock.ExpectQuery(regexp.QuoteMeta("NOT (to_tsvector('simple', concat_ws(' ', title, description)) @@ to_tsquery('simple', ?) OR ")).
+		WithArgs("memo | draft:*", "memo | draft:*").
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns))
+
+	_, err = srv.ReadAll(context.Background(), &todo.ReadAllToDoRequest{Query: "!memo !draft*"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Stats are figures about the todos as a whole.
+type Stats struct {
+	// Open is the number of todos outside the trash.
+	Open int64
+	// Overdue is the number of open todos past their due date.
+	Overdue int64
+}
+
+// CountToDos returns the stats of the todos in db as of now.
+func CountToDos(ctx context.Context, db *sql.DB, now time.Time) (Stats, error) {
+	overdue, dueBefore, dateBefore := overdueCondition(now)
+
+	var s Stats
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM("+overdue+"), 0) FROM todo WHERE deleted_at IS NULL", dueBefore, dateBefore).
+		Scan(&s.Open, &s.Overdue)
+
+	return s, err
+}
@@ -0,0 +1,34 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountToDos(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	assert.NoError(t, err)
+	// it is already 2 January in Jakarta, so a todo due on 1 January is overdue
+	now := time.Date(2030, 1, 1, 20, 0, 0, 0, time.UTC).In(jakarta)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*), COALESCE(SUM(((due_date_only = FALSE AND due_at < ?) OR (due_date_only = TRUE AND due_at < ?))), 0) "+
+		"FROM todo WHERE deleted_at IS NULL")).
+		WithArgs(now, time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"open", "overdue"}).AddRow(5, 2))
+
+	stats, err := service.CountToDos(context.Background(), db, now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, service.Stats{Open: 5, Overdue: 2}, stats)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamAll sends todos while the rows are scanned. Send blocks while the
+// client's flow control window is full, so neither side holds more than a
+// few todos at a time however large the export is.
+func (s *toDoServiceServer) StreamAll(req *todo.ReadAllToDoRequest, stream grpc.ServerStreamingServer[todo.StreamAllResponse]) error {
+	ctx := stream.Context()
+	now := time.Now()
+
+	query, args, err := readAllQuery(req, s.fullText, now)
+	if err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanToDo(rows, now)
+		if err != nil {
+			return status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+
+		if err := stream.Send(&todo.StreamAllResponse{ToDo: t}); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+type exportStream struct {
+	grpc.ServerStream
+	sent []*todo.ToDo
+	err  error
+}
+
+func (s *exportStream) Context() context.Context {
+	return context.Background()
+}
+
+func (s *exportStream) Send(res *todo.StreamAllResponse) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.sent = append(s.sent, res.ToDo)
+	return nil
+}
+
+func TestStreamAll(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL ORDER BY list_id, rank_key ASC, id"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "Dummy Todo 1", "Description 1", time.Now(), nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil).
+			AddRow(2, "Dummy Todo 2", "Description 2", time.Now(), nil, false, 0, nil, 0, "k", nil, 1, 0, 0, false, nil))
+
+	stream := &exportStream{}
+	err = srv.StreamAll(&todo.ReadAllToDoRequest{}, stream)
+
+	assert.NoError(t, err)
+	assert.Len(t, stream.sent, 2)
+	assert.Equal(t, "Dummy Todo 2", stream.sent[1].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamAllClientGone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectQuery("SELECT").
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "Dummy Todo 1", "Description 1", time.Now(), nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+
+	gone := errors.New("transport is closing")
+	err = srv.StreamAll(&todo.ReadAllToDoRequest{}, &exportStream{err: gone})
+
+	assert.ErrorIs(t, err, gone)
+}
@@ -0,0 +1,460 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// fieldDeleted is the clock entry of a todo moving in or out of the trash
+	fieldDeleted = "deleted"
+	// syncBatchSize is how many events or todos a single Sync response covers
+	syncBatchSize = 1000
+	// syncTokenPrefix versions the otherwise opaque sync token
+	syncTokenPrefix = "v1:"
+)
+
+// toDoFields are the fields of a todo whose changes are timestamped for
+// conflict resolution.
+var toDoFields = []string{"title", "description", "reminder", "due_at", "due_date_only", "priority", "reminder_before_due"}
+
+// fieldClock maps field names to when they were last changed.
+type fieldClock map[string]time.Time
+
+func clockAt(at time.Time, fields ...string) fieldClock {
+	clock := make(fieldClock, len(fields))
+	for _, field := range fields {
+		clock[field] = at
+	}
+
+	return clock
+}
+
+// stampFields records when the fields in clock were changed.
+func stampFields(ctx context.Context, tx *sql.Tx, id int64, clock fieldClock) error {
+	if len(clock) == 0 {
+		return nil
+	}
+
+	fields := make([]string, 0, len(clock))
+	for field := range clock {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	values := make([]string, len(fields))
+	args := make([]interface{}, 0, 3*len(fields))
+	for i, field := range fields {
+		values[i] = "(?, ?, ?)"
+		args = append(args, id, field, clock[field])
+	}
+
+	_, err := tx.ExecContext(ctx, "INSERT INTO todo_field_clock(`todo_id`, `field`, `updated_at`) VALUES "+strings.Join(values, ", ")+
+		" ON DUPLICATE KEY UPDATE updated_at = VALUES(updated_at)", args...)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to stamp fields: "+err.Error())
+	}
+
+	return nil
+}
+
+func loadClock(ctx context.Context, tx *sql.Tx, id int64) (fieldClock, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT field, updated_at FROM todo_field_clock WHERE todo_id = ?", id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve field clock: "+err.Error())
+	}
+	defer rows.Close()
+
+	clock := make(fieldClock)
+	for rows.Next() {
+		var (
+			field     string
+			updatedAt time.Time
+		)
+		if err := rows.Scan(&field, &updatedAt); err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan field clock: "+err.Error())
+		}
+
+		clock[field] = updatedAt
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve field clock: "+err.Error())
+	}
+
+	return clock, nil
+}
+
+// syncCursor is where the next sync resumes: after sequence seq or, while
+// the first sync pages through the todos, after todo afterID of the todos
+// as they were at sequence seq.
+type syncCursor struct {
+	seq      int64
+	afterID  int64
+	snapshot bool
+}
+
+func encodeSyncToken(c syncCursor) string {
+	token := syncTokenPrefix + strconv.FormatInt(c.seq, 10)
+	if c.snapshot {
+		token += ":" + strconv.FormatInt(c.afterID, 10)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+func decodeSyncToken(token string) (syncCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), syncTokenPrefix) {
+		return syncCursor{}, fmt.Errorf("invalid sync token")
+	}
+
+	seq, afterID, snapshot := strings.Cut(strings.TrimPrefix(string(b), syncTokenPrefix), ":")
+
+	c := syncCursor{snapshot: snapshot}
+	if c.seq, err = strconv.ParseInt(seq, 10, 64); err != nil || c.seq < 0 {
+		return syncCursor{}, fmt.Errorf("invalid sync token")
+	}
+	if snapshot {
+		if c.afterID, err = strconv.ParseInt(afterID, 10, 64); err != nil || c.afterID <= 0 {
+			return syncCursor{}, fmt.Errorf("invalid sync token")
+		}
+	}
+
+	return c, nil
+}
+
+func validateSyncChange(c *todo.SyncChange) error {
+	if c.GetToDo() == nil {
+		return fmt.Errorf("to_do is required")
+	}
+
+	if c.GetToDo().GetId() == 0 && c.GetClientId() == "" {
+		return fmt.Errorf("client_id is required for a new todo")
+	}
+
+	for field, at := range c.GetFieldTimes() {
+		if field != fieldDeleted && !slices.Contains(toDoFields, field) {
+			return fmt.Errorf("unknown field %q", field)
+		}
+		if err := at.CheckValid(); err != nil {
+			return fmt.Errorf("invalid time for %s: %v", field, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *toDoServiceServer) Sync(ctx context.Context, req *todo.SyncRequest) (*todo.SyncResponse, error) {
+	cursor := syncCursor{seq: -1, snapshot: true}
+	if req.GetSyncToken() != "" {
+		var err error
+		if cursor, err = decodeSyncToken(req.GetSyncToken()); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	if len(req.GetChanges()) > maxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("a sync cannot hold more than %d changes", maxBatchSize))
+	}
+
+	for i, c := range req.GetChanges() {
+		if err := validateSyncChange(c); err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("changes[%d]: %v", i, err))
+		}
+	}
+
+	if err := event.Assign(ctx, s.db); err != nil {
+		return nil, status.Error(codes.Internal, "failed to number events: "+err.Error())
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if cursor.seq >= 0 {
+		if err := checkResumable(ctx, tx, cursor.seq); err != nil {
+			return nil, err
+		}
+	}
+
+	res := &todo.SyncResponse{
+		CreatedIds: make(map[string]int64),
+	}
+
+	// the todos the client changed are sent back with the outcome, as their
+	// events are only numbered once this commits, and those gone from the
+	// server as tombstones even when their deletion is older than the token
+	var changed []int64
+	for i, c := range req.GetChanges() {
+		id, err := applySyncChange(ctx, tx, c, res.CreatedIds)
+		if err != nil {
+			st := status.Convert(err)
+			return nil, status.Error(st.Code(), fmt.Sprintf("changes[%d]: %s", i, st.Message()))
+		}
+		changed = append(changed, id)
+	}
+
+	ids, next, hasMore, err := changedSince(ctx, tx, cursor)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range changed {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range ids {
+		synced, err := loadSyncedToDo(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		res.Changes = append(res.Changes, synced)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	if len(req.GetChanges()) > 0 {
+		s.events.Publish()
+	}
+
+	res.SyncToken = encodeSyncToken(next)
+	res.HasMore = hasMore
+
+	return res, nil
+}
+
+// applySyncChange applies the fields of a client change that are newer than
+// the server's and returns the id of the todo. A todo that no longer exists
+// is left alone. Field times ahead of the server's clock are taken as now,
+// so a client whose clock runs fast does not win every later conflict.
+func applySyncChange(ctx context.Context, tx *sql.Tx, c *todo.SyncChange, created map[string]int64) (int64, error) {
+	now := time.Now()
+	times := make(fieldClock, len(c.GetFieldTimes()))
+	for field, at := range c.GetFieldTimes() {
+		times[field] = at.AsTime()
+		if times[field].After(now) {
+			times[field] = now
+		}
+	}
+
+	if c.GetToDo().GetId() == 0 {
+		// a todo sent again by a retried sync is not created twice
+		principal := util.PrincipalFromContext(ctx)
+		_, err := tx.ExecContext(ctx, "INSERT INTO sync_client_todo(`principal`, `client_id`, `todo_id`) VALUES (?, ?, 0)", principal, c.GetClientId())
+		if isDuplicateKey(err) {
+			var id int64
+			if err := tx.QueryRowContext(ctx, "SELECT todo_id FROM sync_client_todo WHERE principal = ? AND client_id = ?", principal, c.GetClientId()).Scan(&id); err != nil {
+				return 0, status.Error(codes.Internal, "failed to retrieve client id: "+err.Error())
+			}
+
+			created[c.GetClientId()] = id
+			return id, nil
+		}
+		if err != nil {
+			return 0, status.Error(codes.Internal, "failed to claim client id: "+err.Error())
+		}
+
+		t := &todo.ToDo{}
+		applyPatch(t, c.GetToDo(), toDoFieldsIn(times))
+		t.ListId = c.GetToDo().GetListId()
+		if err := validateToDo(t); err != nil {
+			return 0, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		delete(times, fieldDeleted)
+		id, err := insertToDo(ctx, tx, t, times)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE sync_client_todo SET todo_id = ? WHERE principal = ? AND client_id = ?", id, principal, c.GetClientId())
+		if err != nil {
+			return 0, status.Error(codes.Internal, "failed to update client id: "+err.Error())
+		}
+
+		created[c.GetClientId()] = id
+		return id, nil
+	}
+
+	current, err := scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ? FOR UPDATE", c.GetToDo().GetId()), time.Now())
+	if err == sql.ErrNoRows {
+		return c.GetToDo().GetId(), nil
+	}
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	clock, err := loadClock(ctx, tx, current.GetId())
+	if err != nil {
+		return 0, err
+	}
+
+	newer := make(fieldClock)
+	for field, at := range times {
+		if at.After(clock[field]) {
+			newer[field] = at
+		}
+	}
+
+	deletedAt, deletedWins := newer[fieldDeleted]
+	delete(newer, fieldDeleted)
+	wantDeleted := c.GetToDo().GetDeletedAt() != nil
+	trashed := current.GetDeletedAt() != nil
+
+	if deletedWins && !wantDeleted && trashed {
+		if err := restoreToDo(ctx, tx, current.GetId(), deletedAt); err != nil {
+			return 0, err
+		}
+		trashed = false
+	}
+
+	if len(newer) > 0 && !trashed {
+		applyPatch(current, c.GetToDo(), toDoFieldsIn(newer))
+		if err := validateToDo(current); err != nil {
+			return 0, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		// the row is locked, so there is no version to check
+		current.Version = 0
+		if _, err := updateToDo(ctx, tx, current, newer); err != nil {
+			return 0, err
+		}
+	}
+
+	if deletedWins && wantDeleted && !trashed {
+		if err := trashToDo(ctx, tx, current.GetId(), 0, deletedAt); err != nil {
+			return 0, err
+		}
+	}
+
+	return current.GetId(), nil
+}
+
+// toDoFieldsIn returns the todo fields named in clock.
+func toDoFieldsIn(clock fieldClock) []string {
+	var fields []string
+	for _, field := range toDoFields {
+		if _, ok := clock[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// changedSince returns the todos changed after the cursor, oldest change
+// first, with the cursor to resume from and whether more changes remain. A
+// first sync returns the todos that are not in the trash, a page at a time,
+// and then the changes made since it started.
+func changedSince(ctx context.Context, tx *sql.Tx, cursor syncCursor) ([]int64, syncCursor, bool, error) {
+	if cursor.snapshot {
+		return snapshotPage(ctx, tx, cursor)
+	}
+
+	events, err := loadEvents(ctx, tx, cursor.seq, nil, syncBatchSize)
+	if err != nil {
+		return nil, cursor, false, err
+	}
+
+	var ids []int64
+	seen := make(map[int64]bool)
+	for _, e := range events {
+		if !seen[e.ToDoID] {
+			seen[e.ToDoID] = true
+			ids = append(ids, e.ToDoID)
+		}
+		cursor.seq = e.Sequence
+	}
+
+	return ids, cursor, len(events) == syncBatchSize, nil
+}
+
+// snapshotPage returns the next page of the todos that are not in the trash.
+// The sequence of the first page is kept until the last one, so the todos
+// changed in between are sent again as changes afterwards.
+func snapshotPage(ctx context.Context, tx *sql.Tx, cursor syncCursor) ([]int64, syncCursor, bool, error) {
+	if cursor.seq < 0 {
+		last, _, err := event.Sequences(ctx, tx)
+		if err != nil {
+			return nil, cursor, false, status.Error(codes.Internal, "failed to retrieve sequence: "+err.Error())
+		}
+		cursor.seq = last
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM todo WHERE deleted_at IS NULL AND id > ? ORDER BY id LIMIT ?", cursor.afterID, syncBatchSize)
+	if err != nil {
+		return nil, cursor, false, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, cursor, false, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, cursor, false, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+
+	if len(ids) < syncBatchSize {
+		return ids, syncCursor{seq: cursor.seq}, false, nil
+	}
+
+	cursor.afterID = ids[len(ids)-1]
+	return ids, cursor, true, nil
+}
+
+func loadSyncedToDo(ctx context.Context, tx *sql.Tx, id int64) (*todo.SyncedToDo, error) {
+	t, err := scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ?", id), time.Now())
+	if err == sql.ErrNoRows {
+		return &todo.SyncedToDo{
+			ToDo:    &todo.ToDo{Id: id},
+			Deleted: true,
+		}, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	clock, err := loadClock(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	synced := &todo.SyncedToDo{
+		ToDo:       t,
+		FieldTimes: make(map[string]*timestamppb.Timestamp, len(clock)),
+	}
+	for field, at := range clock {
+		synced.FieldTimes[field] = timestamppb.New(at)
+	}
+
+	if t.GetDeletedAt() != nil {
+		synced.ToDo = &todo.ToDo{Id: id, DeletedAt: t.GetDeletedAt()}
+		synced.Deleted = true
+	}
+
+	return synced, nil
+}
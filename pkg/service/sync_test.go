@@ -0,0 +1,376 @@
+package service_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const clockQuery = "SELECT field, updated_at FROM todo_field_clock WHERE todo_id = ?"
+
+const snapshotQuery = "SELECT id FROM todo WHERE deleted_at IS NULL AND id > ? ORDER BY id LIMIT ?"
+
+func syncToken(seq string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + seq))
+}
+
+func TestSyncFromScratch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now().UTC()
+
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 9, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(snapshotQuery)).WithArgs(0, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, now, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).AddRow("title", now))
+	mock.ExpectCommit()
+
+	res, err := srv.Sync(context.Background(), &todo.SyncRequest{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, syncToken("9"), res.SyncToken)
+	assert.False(t, res.HasMore)
+	assert.Len(t, res.Changes, 1)
+	assert.Equal(t, testTitle, res.Changes[0].ToDo.Title)
+	assert.Equal(t, now, res.Changes[0].FieldTimes["title"].AsTime())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncNewerFieldsWin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now().UTC()
+	reminder := now.Add(time.Hour)
+
+	// the client renamed the todo after the server did, but the server's
+	// description is newer than the client's
+	req := &todo.SyncRequest{
+		SyncToken: syncToken("4"),
+		Changes: []*todo.SyncChange{{
+			ToDo: &todo.ToDo{Id: 1, Title: "client title", Description: "client description"},
+			FieldTimes: map[string]*timestamppb.Timestamp{
+				"title":       timestamppb.New(now),
+				"description": timestamppb.New(now.Add(-time.Hour)),
+			},
+		}},
+	}
+
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 3, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery + " FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, reminder, nil, false, 0, nil, 0, "V", nil, 3, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).
+			AddRow("title", now.Add(-time.Minute)).
+			AddRow("description", now.Add(-time.Minute)))
+	expectPreviousReminder(mock, 1, reminder)
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+		WithArgs("client title", testDescription, reminder, nil, false, 0, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	expectStampFields(mock, 1, "title")
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectQuery(regexp.QuoteMeta(eventsQuery)).WithArgs(4, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "todo_id", "list_id", "kind", "created_at"}).
+			AddRow(5, 1, 0, "updated", now))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "client title", testDescription, reminder, nil, false, 0, nil, 0, "V", nil, 4, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).
+			AddRow("title", now).
+			AddRow("description", now.Add(-time.Minute)))
+	mock.ExpectCommit()
+
+	res, err := srv.Sync(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, syncToken("5"), res.SyncToken)
+	assert.Len(t, res.Changes, 1)
+	assert.Equal(t, "client title", res.Changes[0].ToDo.Title)
+	assert.Equal(t, testDescription, res.Changes[0].ToDo.Description)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncInvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	for _, token := range []string{"not-a-token", syncToken("-1"), syncToken("9:0"), syncToken("9:x")} {
+		_, err = srv.Sync(context.Background(), &todo.SyncRequest{SyncToken: token})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), token)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncPagesFirstSync(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now().UTC()
+
+	// a full page leaves the rest of the todos for the next sync
+	ids := sqlmock.NewRows([]string{"id"})
+	for id := 1; id <= 1000; id++ {
+		ids.AddRow(id)
+	}
+
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 9, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(snapshotQuery)).WithArgs(0, 1000).WillReturnRows(ids)
+	for id := 1; id <= 1000; id++ {
+		mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+				AddRow(id, testTitle, testDescription, now, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(id).
+			WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}))
+	}
+	mock.ExpectCommit()
+
+	res, err := srv.Sync(context.Background(), &todo.SyncRequest{})
+
+	assert.NoError(t, err)
+	assert.True(t, res.HasMore)
+	assert.Len(t, res.Changes, 1000)
+	assert.Equal(t, syncToken("9:1000"), res.SyncToken)
+
+	// the last page keeps the sequence of the first one, so the changes
+	// made while paging come next
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 12, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(snapshotQuery)).WithArgs(1000, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1001))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(1001).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1001, testTitle, testDescription, now, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(1001).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}))
+	mock.ExpectCommit()
+
+	res, err = srv.Sync(context.Background(), &todo.SyncRequest{SyncToken: res.SyncToken})
+
+	assert.NoError(t, err)
+	assert.False(t, res.HasMore)
+	assert.Len(t, res.Changes, 1)
+	assert.Equal(t, syncToken("9"), res.SyncToken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// notAfter matches a time no later than the time it holds
+type notAfter time.Time
+
+func (n notAfter) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && !t.After(time.Time(n))
+}
+
+func TestSyncTakesFutureFieldTimesAsNow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now().UTC()
+	reminder := now.Add(time.Hour)
+
+	// the client's clock runs a year fast
+	req := &todo.SyncRequest{
+		SyncToken: syncToken("4"),
+		Changes: []*todo.SyncChange{{
+			ToDo:       &todo.ToDo{Id: 1, Title: "client title"},
+			FieldTimes: map[string]*timestamppb.Timestamp{"title": timestamppb.New(now.AddDate(1, 0, 0))},
+		}},
+	}
+
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 4, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery + " FOR UPDATE")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, reminder, nil, false, 0, nil, 0, "V", nil, 3, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).AddRow("title", now.Add(-time.Minute)))
+	expectPreviousReminder(mock, 1, reminder)
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+		WithArgs("client title", testDescription, reminder, nil, false, 0, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_field_clock")).
+		WithArgs(1, "title", notAfter(now.Add(time.Minute))).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRecordChange(mock, 1, "updated")
+	mock.ExpectQuery(regexp.QuoteMeta(eventsQuery)).WithArgs(4, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "todo_id", "list_id", "kind", "created_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "client title", testDescription, reminder, nil, false, 0, nil, 0, "V", nil, 4, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).AddRow("title", now))
+	mock.ExpectCommit()
+
+	res, err := srv.Sync(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Len(t, res.Changes, 1)
+	assert.Equal(t, "client title", res.Changes[0].ToDo.Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncRetryReturnsCreatedToDo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now().UTC()
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "alice"))
+
+	// the response to the first sync was lost, so the todo is sent again
+	req := &todo.SyncRequest{
+		SyncToken: syncToken("4"),
+		Changes: []*todo.SyncChange{{
+			ToDo:       &todo.ToDo{Title: testTitle},
+			ClientId:   "local-1",
+			FieldTimes: map[string]*timestamppb.Timestamp{"title": timestamppb.New(now)},
+		}},
+	}
+
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 5, 0)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sync_client_todo(`principal`, `client_id`, `todo_id`) VALUES (?, ?, 0)")).
+		WithArgs("alice", "local-1").
+		WillReturnError(errDuplicateKey)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT todo_id FROM sync_client_todo WHERE principal = ? AND client_id = ?")).
+		WithArgs("alice", "local-1").
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id"}).AddRow(8))
+	mock.ExpectQuery(regexp.QuoteMeta(eventsQuery)).WithArgs(4, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "todo_id", "list_id", "kind", "created_at"}).
+			AddRow(5, 8, 0, "created", now))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(8, testTitle, testDescription, now, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).AddRow("title", now))
+	mock.ExpectCommit()
+
+	res, err := srv.Sync(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"local-1": 8}, res.CreatedIds)
+	assert.Len(t, res.Changes, 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncTooManyChanges(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	changes := make([]*todo.SyncChange, 501)
+	for i := range changes {
+		changes[i] = &todo.SyncChange{ToDo: &todo.ToDo{Id: int64(i + 1)}}
+	}
+
+	_, err = srv.Sync(context.Background(), &todo.SyncRequest{Changes: changes})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSyncRecordsClientID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now().UTC()
+	reminder := now.Add(-time.Hour)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "alice"))
+
+	req := &todo.SyncRequest{
+		SyncToken: syncToken("4"),
+		Changes: []*todo.SyncChange{{
+			ToDo:     &todo.ToDo{Title: testTitle, Reminder: timestamppb.New(reminder)},
+			ClientId: "local-1",
+			FieldTimes: map[string]*timestamppb.Timestamp{
+				"title":    timestamppb.New(now),
+				"reminder": timestamppb.New(now),
+			},
+		}},
+	}
+
+	expectAssign(mock)
+	mock.ExpectBegin()
+	expectSequences(mock, 4, 0)
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sync_client_todo(`principal`, `client_id`, `todo_id`) VALUES (?, ?, 0)")).
+		WithArgs("alice", "local-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLastRank(mock, 0, "")
+	mock.ExpectExec("INSERT INTO todo").
+		WithArgs(testTitle, "", reminder, nil, false, 0, nil, 0, "V").
+		WillReturnResult(sqlmock.NewResult(8, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO notification")).
+		WithArgs("alice", "reminder", 8, testTitle, reminder).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 8, "reminder", "title")
+	expectRecordChange(mock, 8, "created")
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sync_client_todo SET todo_id = ? WHERE principal = ? AND client_id = ?")).
+		WithArgs(8, "alice", "local-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// the event of the new todo is numbered once the sync commits
+	mock.ExpectQuery(regexp.QuoteMeta(eventsQuery)).WithArgs(4, 1000).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "todo_id", "list_id", "kind", "created_at"}))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(8, testTitle, "", reminder, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(clockQuery)).WithArgs(8).
+		WillReturnRows(sqlmock.NewRows([]string{"field", "updated_at"}).AddRow("title", now))
+	mock.ExpectCommit()
+
+	res, err := srv.Sync(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"local-1": 8}, res.CreatedIds)
+	assert.Len(t, res.Changes, 1)
+	assert.Equal(t, syncToken("4"), res.SyncToken)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
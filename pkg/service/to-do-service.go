@@ -3,46 +3,344 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
 	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"github.com/ariefro/simple-to-do-service/pkg/logging"
+	"github.com/ariefro/simple-to-do-service/pkg/notify"
 	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"github.com/go-sql-driver/mysql"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const toDoColumns = "id, title, description, reminder, due_at, due_date_only, priority, reminder_offset_seconds, list_id, rank_key, deleted_at, version, " +
+	"(SELECT COUNT(*) FROM checklist_item c WHERE c.todo_id = todo.id), " +
+	"(SELECT COUNT(*) FROM checklist_item c WHERE c.todo_id = todo.id AND c.checked), done, " +
+	"(SELECT GROUP_CONCAT(label ORDER BY label SEPARATOR ',') FROM todo_label l WHERE l.todo_id = todo.id)"
+
 // toDoServiceServer is implementation of ToDoServiceServer proto interface
 type toDoServiceServer struct {
 	todo.UnimplementedToDoServiceServer
 	db *(sql.DB)
+
+	// blobs stores attachment contents; attachments are disabled when nil
+	blobs             blob.Store
+	maxAttachmentSize int64
+
+	// idempotencyWindow is how long Create remembers an idempotency key
+	idempotencyWindow time.Duration
+
+	// undoWindow is how long an undo token stays valid
+	undoWindow time.Duration
+
+	// events wakes up Watch streams after changes are committed
+	events *event.Bus
+
+	// fullText is the full-text search of the database
+	fullText fullText
+}
+
+func NewTodoServiceServer(db *sql.DB, opts ...Option) todo.ToDoServiceServer {
+	s := &toDoServiceServer{
+		db:                db,
+		idempotencyWindow: DefaultIdempotencyWindow,
+		undoWindow:        DefaultUndoWindow,
+		events:            event.NewBus(),
+		fullText:          mysqlFullText{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// rowQueryer is satisfied by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// missingOrStale explains why a write to a todo matched no row: the todo
+// does not exist (or is trashed), or its version no longer matches.
+func missingOrStale(ctx context.Context, db rowQueryer, id int64) error {
+	var version int64
+	err := db.QueryRowContext(ctx, "SELECT version FROM todo WHERE id = ? AND deleted_at IS NULL", id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return status.Error(codes.NotFound, "todo not found")
+	}
+	if err != nil {
+		return status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	return status.Errorf(codes.Aborted, "todo has been modified, current version is %d", version)
+}
+
+// erDupEntry is the MySQL error number of a write violating a unique key
+const erDupEntry = 1062
+
+// isDuplicateKey reports whether err is a write violating a unique key.
+func isDuplicateKey(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == erDupEntry
+}
+
+// scanToDo reads a row selected with toDoColumns
+func scanToDo(row rowScanner, now time.Time) (*todo.ToDo, error) {
+	var (
+		id             int64
+		title          string
+		description    string
+		reminder       time.Time
+		dueAt          sql.NullTime
+		dueDateOnly    bool
+		priority       int32
+		reminderOffset sql.NullInt64
+		listID         int64
+		rankKey        string
+		deletedAt      sql.NullTime
+		version        int64
+		total          int32
+		checked        int32
+		done           bool
+		labels         sql.NullString
+	)
+
+	if err := row.Scan(&id, &title, &description, &reminder, &dueAt, &dueDateOnly, &priority, &reminderOffset, &listID, &rankKey,
+		&deletedAt, &version, &total, &checked, &done, &labels); err != nil {
+		return nil, err
+	}
+
+	t := &todo.ToDo{
+		Id:               id,
+		Title:            title,
+		Description:      description,
+		Reminder:         timestamppb.New(reminder),
+		DueDateOnly:      dueDateOnly,
+		Priority:         todo.Priority(priority),
+		ListId:           listID,
+		RankKey:          rankKey,
+		Version:          version,
+		ChecklistTotal:   total,
+		ChecklistChecked: checked,
+		Done:             done,
+	}
+
+	if dueAt.Valid {
+		t.DueAt = timestamppb.New(dueAt.Time)
+		t.Overdue = isOverdue(dueAt.Time, dueDateOnly, now)
+	}
+
+	if deletedAt.Valid {
+		t.DeletedAt = timestamppb.New(deletedAt.Time)
+	}
+
+	if reminderOffset.Valid {
+		t.ReminderBeforeDue = durationpb.New(time.Duration(reminderOffset.Int64) * time.Second)
+	}
+
+	if labels.Valid {
+		t.Labels = strings.Split(labels.String, ",")
+	}
+
+	return t, nil
 }
 
-func NewTodoServiceServer(db *sql.DB) todo.ToDoServiceServer {
-	return &toDoServiceServer{db: db}
+// isOverdue reports whether a todo due at dueAt has passed its deadline. A
+// date-only todo is due by the end of its day in the location of now.
+func isOverdue(dueAt time.Time, dateOnly bool, now time.Time) bool {
+	if dateOnly {
+		return dueAt.Before(utcDate(now))
+	}
+
+	return now.After(dueAt)
+}
+
+func validateToDo(t *todo.ToDo) error {
+	if err := util.ValidateTitle(t.GetTitle()); err != nil {
+		return err
+	}
+
+	if err := util.ValidatePriority(t.GetPriority()); err != nil {
+		return err
+	}
+
+	if err := util.ValidateDueAt(t.GetDueAt(), t.GetDueDateOnly()); err != nil {
+		return err
+	}
+
+	return util.ValidateReminderBeforeDue(t.GetReminderBeforeDue(), t.GetDueAt())
+}
+
+// schedulingArgs returns the reminder, due_at and reminder offset values to
+// store for a todo. A reminder relative to the due date wins over an
+// absolute one.
+func schedulingArgs(t *todo.ToDo) (time.Time, sql.NullTime, sql.NullInt64) {
+	reminder := t.GetReminder().AsTime()
+
+	var dueAt sql.NullTime
+	if t.GetDueAt() != nil {
+		dueAt = sql.NullTime{Time: t.GetDueAt().AsTime(), Valid: true}
+	}
+
+	var offset sql.NullInt64
+	if t.GetReminderBeforeDue() != nil {
+		before := t.GetReminderBeforeDue().AsDuration()
+		offset = sql.NullInt64{Int64: int64(before / time.Second), Valid: true}
+		reminder = dueAt.Time.Add(-before)
+	}
+
+	return reminder, dueAt, offset
+}
+
+// reminderMoved reports whether a stored reminder differs from the new one.
+// The column keeps whole seconds, so a smaller difference is rounding.
+func reminderMoved(previous, reminder time.Time) bool {
+	d := reminder.Sub(previous)
+	return d <= -time.Second || d >= time.Second
+}
+
+// scheduleReminder queues the reminder of a todo for the caller who set it.
+// Todos without an explicit or relative reminder, or written by an anonymous
+// caller, get no notification.
+func scheduleReminder(ctx context.Context, tx *sql.Tx, id int64, t *todo.ToDo, reminder time.Time) error {
+	principal := util.PrincipalFromContext(ctx)
+	if principal == "" || (t.GetReminder() == nil && t.GetReminderBeforeDue() == nil) {
+		return nil
+	}
+
+	err := notify.Enqueue(ctx, tx, notify.Notification{
+		Recipient: principal,
+		Kind:      notify.KindReminder,
+		ToDoID:    id,
+		Message:   t.GetTitle(),
+		DeliverAt: reminder,
+	})
+	if err != nil {
+		return status.Error(codes.Internal, "failed to schedule reminder: "+err.Error())
+	}
+
+	return nil
 }
 
 func (s *toDoServiceServer) Create(ctx context.Context, req *todo.CreateToDoRequest) (*todo.CreateToDoResponse, error) {
-	err := util.ValidateTitle(req.ToDo.GetTitle())
+	if err := validateToDo(req.GetToDo()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	key, err := util.IdempotencyKey(ctx, req.GetIdempotencyKey())
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	reminder := req.ToDo.GetReminder().AsTime()
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	res, err := s.db.ExecContext(ctx, "INSERT INTO todo(`title`, `description`,`reminder`) VALUES (?, ?, ?)", req.ToDo.GetTitle(), req.ToDo.GetDescription(), reminder)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to insert into todo: "+err.Error())
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	var idem *idempotentCall
+	if key != "" {
+		idem, err = beginIdempotentCall(ctx, tx, util.PrincipalFromContext(ctx), key, req.GetToDo(), time.Now().Add(s.idempotencyWindow))
+		if err != nil {
+			return nil, err
+		}
+		if idem.replay != nil {
+			logging.FromContext(ctx).DebugContext(ctx, "replaying create", slog.String("idempotency_key", key), slog.Int64("id", idem.replay.GetId()))
+			return idem.replay, nil
+		}
+	}
+
+	id, err := insertToDo(ctx, tx, req.GetToDo(), clockAt(time.Now(), toDoFields...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := flushUndo(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	created := &todo.CreateToDoResponse{
+		Id:        id,
+		UndoToken: undo.Token(),
+	}
+
+	if idem != nil {
+		if err := idem.finish(ctx, tx, created); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	return created, nil
+}
+
+// insertToDo inserts a validated todo at the end of its list, schedules its
+// reminder and stamps its fields with clock.
+func insertToDo(ctx context.Context, tx *sql.Tx, t *todo.ToDo, clock fieldClock) (int64, error) {
+	reminder, dueAt, offset := schedulingArgs(t)
+
+	// new todos go to the end of their list
+	rankKey, err := lastRankKey(ctx, tx, t.GetListId())
+	if err != nil {
+		return 0, err
+	}
+	rankKey, err = util.RankBetween(rankKey, "")
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to compute rank key: "+err.Error())
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO todo(`title`, `description`, `reminder`, `due_at`, `due_date_only`, `priority`, `reminder_offset_seconds`, `list_id`, `rank_key`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		t.GetTitle(), t.GetDescription(), reminder, dueAt, t.GetDueDateOnly(), int32(t.GetPriority()), offset,
+		t.GetListId(), rankKey)
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to insert into todo: "+err.Error())
 	}
 
 	id, err := res.LastInsertId()
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to retrieve id for created todo: "+err.Error())
+		return 0, status.Error(codes.Internal, "failed to retrieve id for created todo: "+err.Error())
 	}
 
-	return &todo.CreateToDoResponse{
-		Id: id,
-	}, nil
+	if err := scheduleReminder(ctx, tx, id, t, reminder); err != nil {
+		return 0, err
+	}
+
+	if err := stampFields(ctx, tx, id, clock); err != nil {
+		return 0, err
+	}
+
+	if err := recordChange(ctx, tx, id, event.KindCreated); err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
 func (s *toDoServiceServer) Read(ctx context.Context, req *todo.ReadToDoRequest) (*todo.ReadToDoResponse, error) {
@@ -50,15 +348,15 @@ func (s *toDoServiceServer) Read(ctx context.Context, req *todo.ReadToDoRequest)
 		return nil, status.Error(codes.InvalidArgument, "todo id is required")
 	}
 
-	query := "SELECT id, title, description, reminder FROM todo Where id = ?"
+	query := "SELECT " + toDoColumns + " FROM todo WHERE id = ?"
+	if !req.GetIncludeTrashed() {
+		query += " AND deleted_at IS NULL"
+	}
 
 	row := s.db.QueryRowContext(ctx, query, req.Id)
 
-	var id int64
-	var title, description string
-	var reminder time.Time
-
-	if err := row.Scan(&id, &title, &description, &reminder); err != nil {
+	t, err := scanToDo(row, time.Now())
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, status.Error(codes.NotFound, "todo not found")
 		}
@@ -66,54 +364,56 @@ func (s *toDoServiceServer) Read(ctx context.Context, req *todo.ReadToDoRequest)
 		return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
 	}
 
-	reminderProto := timestamppb.New(reminder)
+	if t.Checklist, err = s.listChecklist(ctx, t.Id); err != nil {
+		return nil, err
+	}
 
 	return &todo.ReadToDoResponse{
-		ToDo: &todo.ToDo{
-			Id:          id,
-			Title:       title,
-			Description: description,
-			Reminder:    reminderProto,
-		},
+		ToDo: t,
 	}, nil
 }
 
+// readAllQuery builds the query listing the todos selected by a ReadAll
+// request.
+func readAllQuery(req *todo.ReadAllToDoRequest, ft fullText, now time.Time) (string, []interface{}, error) {
+	var where whereClause
+	if !req.GetIncludeTrashed() {
+		where.add("deleted_at IS NULL")
+	}
+	applyFilter(&where, req.GetFilter(), now)
+	if _, err := applyQuery(&where, ft, req.GetQuery(), now); err != nil {
+		return "", nil, err
+	}
+
+	return "SELECT " + toDoColumns + " FROM todo" + where.String() + orderBy(req.GetSortBy(), req.GetDescending()), where.args, nil
+}
+
 func (s *toDoServiceServer) ReadAll(ctx context.Context, req *todo.ReadAllToDoRequest) (*todo.ReadAllToDoResponse, error) {
-	query := "SELECT id, title, description, reminder FROM todo"
+	now := time.Now()
 
-	rows, err := s.db.QueryContext(ctx, query)
+	query, args, err := readAllQuery(req, s.fullText, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
 	}
 	// defer closing rows to ensure it runs after data has been processed
 	defer rows.Close()
 
-
 	var todos []*todo.ToDo
 	for rows.Next() {
-		var(
-			id int64
-			title string
-			description string
-			reminder time.Time
-		)
-
 		// scan the values from the current row
-		if err := rows.Scan(&id, &title,&description, &reminder); err != nil {
+		t, err := scanToDo(rows, now)
+		if err != nil {
 			return nil, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
 		}
 
-		// Convert reminder to protobuf timestamp
-		reminderProto := timestamppb.New(reminder)
-
-		todos = append(todos, &todo.ToDo{
-			Id: id,
-			Title: title,
-			Description: description,
-			Reminder: reminderProto,
-		})
+		todos = append(todos, t)
 	}
-	
+
 	// Check if there was an error during row iteration
 	if err := rows.Err(); err != nil {
 		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
@@ -129,52 +429,161 @@ func (s *toDoServiceServer) Update(ctx context.Context, req *todo.UpdateToDoRequ
 		return nil, status.Error(codes.InvalidArgument, "todo id is required")
 	}
 
-	if err := util.ValidateTitle(req.ToDo.GetTitle()); err != nil {
+	if err := validateToDo(req.GetToDo()); err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	query := "UPDATE todo SET title = ?, description = ?, reminder = ? WHERE id = ?"
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	reminder := req.ToDo.GetReminder().AsTime()
-	res, err := s.db.ExecContext(ctx, query, req.ToDo.GetTitle(), req.ToDo.GetDescription(), reminder, req.ToDo.GetId())
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to update todo: " + err.Error())
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := res.RowsAffected()
+	version, err := updateToDo(ctx, tx, req.GetToDo(), clockAt(time.Now(), toDoFields...))
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to retrieve affected rows: " + err.Error())
+		return nil, err
 	}
-	if rowsAffected == 0 {
-		return nil, status.Error(codes.NotFound, "todo not found")
+
+	if err := flushUndo(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
 	}
+	s.events.Publish()
 
 	return &todo.UpdateToDoResponse{
-		Success: true,
+		Success:   true,
+		Version:   version,
+		UndoToken: undo.Token(),
 	}, nil
 }
 
+// updateToDo overwrites a validated todo, reschedules its reminder if it
+// moved, stamps the fields in clock and returns its new version.
+func updateToDo(ctx context.Context, tx *sql.Tx, t *todo.ToDo, clock fieldClock) (int64, error) {
+	query := "UPDATE todo SET title = ?, description = ?, reminder = ?, due_at = ?, due_date_only = ?, priority = ?, reminder_offset_seconds = ?, " +
+		"version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+
+	reminder, dueAt, offset := schedulingArgs(t)
+	args := []interface{}{t.GetTitle(), t.GetDescription(), reminder,
+		dueAt, t.GetDueDateOnly(), int32(t.GetPriority()), offset, t.GetId()}
+
+	var previous time.Time
+	err := tx.QueryRowContext(ctx, "SELECT reminder FROM todo WHERE id = ? AND deleted_at IS NULL", t.GetId()).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return 0, status.Error(codes.NotFound, "todo not found")
+	}
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+	}
+
+	// a version makes the update conditional on nobody else having changed the todo
+	if t.GetVersion() != 0 {
+		query += " AND version = ?"
+		args = append(args, t.GetVersion())
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to update todo: "+err.Error())
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to retrieve affected rows: "+err.Error())
+	}
+	if rowsAffected == 0 {
+		return 0, missingOrStale(ctx, tx, t.GetId())
+	}
+
+	var version int64
+	if err := tx.QueryRowContext(ctx, "SELECT version FROM todo WHERE id = ?", t.GetId()).Scan(&version); err != nil {
+		return 0, status.Error(codes.Internal, "failed to retrieve version: "+err.Error())
+	}
+
+	// only a reminder that moved is rescheduled, so changing anything else
+	// does not queue a reminder that has already gone off again
+	if reminderMoved(previous, reminder) {
+		if err := notify.CancelPending(ctx, tx, t.GetId(), notify.KindReminder); err != nil {
+			return 0, status.Error(codes.Internal, "failed to cancel reminder: "+err.Error())
+		}
+		if reminder.After(time.Now()) {
+			if err := scheduleReminder(ctx, tx, t.GetId(), t, reminder); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := stampFields(ctx, tx, t.GetId(), clock); err != nil {
+		return 0, err
+	}
+
+	if err := recordChange(ctx, tx, t.GetId(), event.KindUpdated); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
 func (s *toDoServiceServer) Delete(ctx context.Context, req *todo.DeleteRequest) (*todo.DeleteResponse, error) {
 	if req.GetId() == 0 {
 		return nil, status.Error(codes.InvalidArgument, "todo id is required")
 	}
 
-	query := "DELETE FROM todo WHERE id = ?"
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	res, err := s.db.ExecContext(ctx, query, req.GetId())
+	err = s.applyInTx(ctx, func(tx *sql.Tx) error {
+		return trashToDo(ctx, tx, req.GetId(), req.GetVersion(), time.Now())
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to delete todo: " + err.Error())
+		return nil, err
+	}
+	s.events.Publish()
+
+	return &todo.DeleteResponse{
+		Success:   true,
+		UndoToken: undo.Token(),
+	}, nil
+}
+
+// trashToDo moves a todo to the trash; PurgeTrash deletes it for good. A
+// non-zero version must match the todo's current version. The deletion is
+// stamped with at.
+func trashToDo(ctx context.Context, tx *sql.Tx, id, version int64, at time.Time) error {
+	query := "UPDATE todo SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+	args := []interface{}{time.Now(), id}
+
+	if version != 0 {
+		query += " AND version = ?"
+		args = append(args, version)
+	}
+
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to delete todo: "+err.Error())
 	}
 
 	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return nil, status.Error(codes.Internal, "failed to retrieve affected rows: " + err.Error())
+		return status.Error(codes.Internal, "failed to retrieve affected rows: "+err.Error())
 	}
 	if rowsAffected == 0 {
-		return nil, status.Error(codes.NotFound, "todo not found")
+		return missingOrStale(ctx, tx, id)
 	}
 
-	return &todo.DeleteResponse{
-		Success: true,
-	}, nil
+	if err := stampFields(ctx, tx, id, clockAt(at, fieldDeleted)); err != nil {
+		return err
+	}
+
+	return recordChange(ctx, tx, id, event.KindDeleted)
 }
@@ -3,6 +3,7 @@ package service_test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"regexp"
 	"testing"
 	"time"
@@ -12,13 +13,92 @@ import (
 	"github.com/ariefro/simple-to-do-service/pkg/service"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const testTitle = "Dummy title"
 const testDescription = "This is a test description"
-const readQuery = "SELECT id, title, description, reminder FROM todo Where id = ?"
+const toDoColumns = "id, title, description, reminder, due_at, due_date_only, priority, reminder_offset_seconds, list_id, rank_key, deleted_at, version, " +
+	"(SELECT COUNT(*) FROM checklist_item c WHERE c.todo_id = todo.id), " +
+	"(SELECT COUNT(*) FROM checklist_item c WHERE c.todo_id = todo.id AND c.checked), done, " +
+	"(SELECT GROUP_CONCAT(label ORDER BY label SEPARATOR ',') FROM todo_label l WHERE l.todo_id = todo.id)"
+const checklistQuery = "SELECT id, text, checked, position FROM checklist_item WHERE todo_id = ? ORDER BY position, id"
+const readQuery = "SELECT " + toDoColumns + " FROM todo WHERE id = ? AND deleted_at IS NULL"
+const updateQuery = "UPDATE todo SET title = ?, description = ?, reminder = ?, due_at = ?, due_date_only = ?, priority = ?, reminder_offset_seconds = ?, " +
+	"version = version + 1 WHERE id = ? AND deleted_at IS NULL"
+
+const recordChangeQuery = "INSERT INTO todo_event(`todo_id`, `list_id`, `kind`, `created_at`) SELECT id, list_id, ?, ? FROM todo WHERE id = ?"
+
+const lastRevisionQuery = "SELECT snapshot FROM todo_revision WHERE todo_id = ? ORDER BY id DESC LIMIT 1"
+
+const insertRevisionQuery = "INSERT INTO todo_revision(`todo_id`, `actor`, `kind`, `snapshot`, `changes`, `created_at`) VALUES (?, ?, ?, ?, ?, ?)"
+
+const undoEntryQuery = "INSERT INTO undo_entry(`token`, `todo_id`, `principal`, `version`, `snapshot`, `expires_at`) VALUES (?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE version = VALUES(version)"
+
+const versionQuery = "SELECT version FROM todo WHERE id = ? AND deleted_at IS NULL"
+
+const cancelReminderQuery = "DELETE FROM notification WHERE todo_id = ? AND kind = ? AND delivered_at IS NULL"
+
+const previousReminderQuery = "SELECT reminder FROM todo WHERE id = ? AND deleted_at IS NULL"
+
+const lastRankQuery = "SELECT rank_key FROM todo WHERE list_id = ? ORDER BY rank_key DESC LIMIT 1 FOR UPDATE"
+
+var toDoRowColumns = []string{"id", "title", "description", "reminder", "due_at", "due_date_only", "priority", "reminder_offset_seconds", "list_id", "rank_key", "deleted_at", "version", "checklist_total", "checklist_checked", "done", "labels"}
+
+// toDoFields are the timestamped fields of a todo, sorted
+var toDoFields = []string{"description", "due_at", "due_date_only", "priority", "reminder", "reminder_before_due", "title"}
+
+func expectStampFields(mock sqlmock.Sqlmock, id int64, fields ...string) {
+	var args []driver.Value
+	for _, field := range fields {
+		args = append(args, id, field, sqlmock.AnyArg())
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO todo_field_clock")).WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(fields))))
+}
+
+// expectPreviousReminder mocks the lookup of the stored reminder of a todo
+// about to be updated
+func expectPreviousReminder(mock sqlmock.Sqlmock, id int64, reminder time.Time) {
+	mock.ExpectQuery(regexp.QuoteMeta(previousReminderQuery)).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"reminder"}).AddRow(reminder))
+}
+
+// expectRecordChange mocks the change feed entry and the first revision of
+// a todo
+func expectRecordChange(mock sqlmock.Sqlmock, id int64, kind string) {
+	mock.ExpectExec(regexp.QuoteMeta(recordChangeQuery)).WithArgs(kind, sqlmock.AnyArg(), id).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ?")).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(id, testTitle, testDescription, time.Now(), nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(lastRevisionQuery)).WithArgs(id).
+		WillReturnRows(sqlmock.NewRows([]string{"snapshot"}))
+	mock.ExpectExec(regexp.QuoteMeta(insertRevisionQuery)).
+		WithArgs(id, sqlmock.AnyArg(), "created", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// expectUndoEntry mocks the write of the undo entry of a todo changed by a
+// call that can be undone
+func expectUndoEntry(mock sqlmock.Sqlmock, id int64) {
+	mock.ExpectExec(regexp.QuoteMeta(undoEntryQuery)).
+		WithArgs(sqlmock.AnyArg(), id, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectLastRank mocks the lookup of the last rank key of a list
+func expectLastRank(mock sqlmock.Sqlmock, listID int64, rankKey string) {
+	rows := sqlmock.NewRows([]string{"rank_key"})
+	if rankKey != "" {
+		rows.AddRow(rankKey)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(lastRankQuery)).WithArgs(listID).WillReturnRows(rows)
+}
 
 func TestCreateToDoSuccess(t *testing.T) {
 	// mock database
@@ -41,9 +121,15 @@ func TestCreateToDoSuccess(t *testing.T) {
 	}
 
 	// mock database behavior for success case
+	mock.ExpectBegin()
+	expectLastRank(mock, 0, "")
 	mock.ExpectExec("INSERT INTO todo").
-		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime()).
+		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime(), nil, false, 0, nil, 0, "V").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "created")
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
 
 	res, err := srv.Create(context.Background(), req)
 
@@ -91,9 +177,12 @@ func TestCreateToDoDatabaseError(t *testing.T) {
 		},
 	}
 
+	mock.ExpectBegin()
+	expectLastRank(mock, 0, "")
 	mock.ExpectExec("INSERT INTO todo").
-		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime()).
+		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime(), nil, false, 0, nil, 0, "V").
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	res, err := srv.Create(context.Background(), req)
 
@@ -111,10 +200,15 @@ func TestReadToDoSuccess(t *testing.T) {
 
 	srv := service.NewTodoServiceServer(db)
 
-	mock.ExpectQuery(readQuery).
+	mock.ExpectQuery(regexp.QuoteMeta(readQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, time.Now(), nil, false, 0, nil, 0, "V", nil, 1, 2, 1, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(checklistQuery)).
 		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "reminder"}).
-			AddRow(1, testTitle, testDescription, time.Now()))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "checked", "position"}).
+			AddRow(1, "first step", true, 0).
+			AddRow(2, "second step", false, 1))
 
 	req := &todo.ReadToDoRequest{
 		Id: 1,
@@ -126,6 +220,9 @@ func TestReadToDoSuccess(t *testing.T) {
 	assert.NotNil(t, res)
 	assert.Equal(t, int64(1), res.ToDo.Id)
 	assert.Equal(t, testTitle, res.ToDo.Title)
+	assert.Len(t, res.ToDo.Checklist, 2)
+	assert.Equal(t, int32(2), res.ToDo.ChecklistTotal)
+	assert.Equal(t, int32(1), res.ToDo.ChecklistChecked)
 }
 
 func TestReadToDoNotFound(t *testing.T) {
@@ -135,7 +232,7 @@ func TestReadToDoNotFound(t *testing.T) {
 
 	srv := service.NewTodoServiceServer(db)
 
-	mock.ExpectQuery(readQuery).
+	mock.ExpectQuery(regexp.QuoteMeta(readQuery)).
 		WithArgs(1).
 		WillReturnRows(sqlmock.NewRows([]string{}))
 
@@ -158,7 +255,7 @@ func TestReadToDoQueryError(t *testing.T) {
 
 	srv := service.NewTodoServiceServer(db)
 
-	mock.ExpectQuery(readQuery).
+	mock.ExpectQuery(regexp.QuoteMeta(readQuery)).
 		WithArgs(1).
 		WillReturnError(sql.ErrConnDone)
 
@@ -179,14 +276,14 @@ func TestReadAllToDoSuccess(t *testing.T) {
 	assert.NoError(t, err)
 	defer db.Close()
 
-	query := "SELECT id, title, description, reminder FROM todo"
-	rows := sqlmock.NewRows([]string{"id", "title", "description", "reminder"}).
-		AddRow(1, "Dummy Todo 1", "Description 1", time.Now()).
-		AddRow(2, "Dummy Todo 2", "Description 2", time.Now())
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL ORDER BY list_id, rank_key ASC, id"
+	rows := sqlmock.NewRows(toDoRowColumns).
+		AddRow(1, "Dummy Todo 1", "Description 1", time.Now(), nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil).
+		AddRow(2, "Dummy Todo 2", "Description 2", time.Now(), nil, false, 0, nil, 0, "k", nil, 1, 0, 0, false, nil)
 
 	svc := service.NewTodoServiceServer(db)
 
-	mock.ExpectQuery(query).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(rows)
 
 	req := &todo.ReadAllToDoRequest{}
 	res, err := svc.ReadAll(context.Background(), req)
@@ -215,21 +312,116 @@ func TestUpdateToDoSuccess(t *testing.T) {
 
 	req := &todo.UpdateToDoRequest{
 		ToDo: &todo.ToDo{
-			Id: 1,
-			Title: "Updated Title",
+			Id:          1,
+			Title:       "Updated Title",
 			Description: "Updated description",
-			Reminder: timestamppb.Now(),
+			Reminder:    timestamppb.Now(),
 		},
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET title = ?, description = ?, reminder = ? WHERE id = ?")).
-		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime(), req.ToDo.GetId()).
+	mock.ExpectBegin()
+	expectPreviousReminder(mock, req.ToDo.GetId(), req.ToDo.GetReminder().AsTime().Add(-time.Hour))
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime(), nil, false, 0, nil, req.ToDo.GetId()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(req.ToDo.GetId()).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectExec(regexp.QuoteMeta(cancelReminderQuery)).
+		WithArgs(req.ToDo.GetId(), "reminder").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, req.ToDo.GetId(), toDoFields...)
+	expectRecordChange(mock, req.ToDo.GetId(), "updated")
+	expectUndoEntry(mock, req.ToDo.GetId())
+	mock.ExpectCommit()
 
 	res, err := svc.Update(context.Background(), req)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
+	assert.Equal(t, int64(4), res.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateToDoReschedulesOnlyMovedFutureReminders(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	future := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	tests := []struct {
+		name               string
+		previous, reminder time.Time
+		cancel, enqueue    bool
+	}{
+		{"unchanged past reminder", past, past, false, false},
+		{"unchanged future reminder", future, future, false, false},
+		{"moved into the past", future, past, true, false},
+		{"moved into the future", past, future, true, true},
+	}
+
+	for _, tt := range tests {
+		mock.ExpectBegin()
+		expectPreviousReminder(mock, 1, tt.previous)
+		mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+			WithArgs("Renamed", testDescription, tt.reminder, nil, false, 0, nil, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+		if tt.cancel {
+			mock.ExpectExec(regexp.QuoteMeta(cancelReminderQuery)).WithArgs(1, "reminder").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		if tt.enqueue {
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO notification")).WithArgs("alice", "reminder", 1, "Renamed", tt.reminder).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		expectStampFields(mock, 1, toDoFields...)
+		expectRecordChange(mock, 1, "updated")
+		expectUndoEntry(mock, 1)
+		mock.ExpectCommit()
+
+		_, err := svc.Update(asUser("alice"), &todo.UpdateToDoRequest{
+			ToDo: &todo.ToDo{Id: 1, Title: "Renamed", Description: testDescription, Reminder: timestamppb.New(tt.reminder)},
+		})
+
+		assert.NoError(t, err, tt.name)
+		assert.NoError(t, mock.ExpectationsWereMet(), tt.name)
+	}
+}
+
+func TestUpdateToDoStaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	req := &todo.UpdateToDoRequest{
+		ToDo: &todo.ToDo{
+			Id:          1,
+			Title:       "Updated Title",
+			Description: "Updated description",
+			Reminder:    timestamppb.Now(),
+			Version:     2,
+		},
+	}
+
+	mock.ExpectBegin()
+	expectPreviousReminder(mock, req.ToDo.GetId(), req.ToDo.GetReminder().AsTime())
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery+" AND version = ?")).
+		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime(), nil, false, 0, nil, req.ToDo.GetId(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectRollback()
+
+	res, err := srv.Update(context.Background(), req)
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.Aborted, status.Code(err))
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -249,9 +441,10 @@ func TestUpdateToDoNotFound(t *testing.T) {
 		},
 	}
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET title = ?, description = ?, reminder = ? WHERE id = ?")).
-		WithArgs(req.ToDo.GetTitle(), req.ToDo.GetDescription(), req.ToDo.GetReminder().AsTime(), req.ToDo.GetId()).
-		WillReturnResult(sqlmock.NewResult(1, 0)) // No rows updated
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(previousReminderQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"reminder"}))
+	mock.ExpectRollback()
 
 	res, err := srv.Update(context.Background(), req)
 
@@ -291,9 +484,14 @@ func TestDeleteToDoSuccess(t *testing.T) {
 
 	svc := service.NewTodoServiceServer(db)
 
-	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo WHERE id = ?")).
-		WithArgs(1).
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL")).
+		WithArgs(sqlmock.AnyArg(), 1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, 1, "deleted")
+	expectRecordChange(mock, 1, "deleted")
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
 
 	req := &todo.DeleteRequest{
 		Id: 1,
@@ -314,9 +512,13 @@ func TestDeleteToDoNotFound(t *testing.T) {
 
 	svc := service.NewTodoServiceServer(db)
 
-	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo WHERE id = ?")).
-		WithArgs(1).
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL")).
+		WithArgs(sqlmock.AnyArg(), 1).
 		WillReturnResult(sqlmock.NewResult(0, 0)) // No rows deleted
+	mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
 
 	req := &todo.DeleteRequest{
 		Id: 1,
@@ -328,4 +530,192 @@ func TestDeleteToDoNotFound(t *testing.T) {
 	assert.Nil(t, res)
 	assert.Equal(t, codes.NotFound, status.Code(err))
 	assert.NoError(t, mock.ExpectationsWereMet())
-}
\ No newline at end of file
+}
+
+func TestDeleteToDoStaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = ?, version = version + 1 WHERE id = ? AND deleted_at IS NULL AND version = ?")).
+		WithArgs(sqlmock.AnyArg(), 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(versionQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectRollback()
+
+	res, err := svc.Delete(context.Background(), &todo.DeleteRequest{Id: 1, Version: 2})
+
+	assert.Nil(t, res)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateToDoWithDueAtAndPriority(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	dueAt := time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)
+	req := &todo.CreateToDoRequest{
+		ToDo: &todo.ToDo{
+			Title:             testTitle,
+			Description:       testDescription,
+			DueAt:             timestamppb.New(dueAt),
+			Priority:          todo.Priority_PRIORITY_P1,
+			ReminderBeforeDue: durationpb.New(time.Hour),
+		},
+	}
+
+	// the reminder is derived from the due date
+	mock.ExpectBegin()
+	expectLastRank(mock, 0, "V")
+	mock.ExpectExec("INSERT INTO todo").
+		WithArgs(testTitle, testDescription, dueAt.Add(-time.Hour), dueAt, false, 1, 3600, 0, "l").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "created")
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
+
+	res, err := srv.Create(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateToDoInvalidScheduling(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	tests := []struct {
+		name string
+		todo *todo.ToDo
+		msg  string
+	}{
+		{
+			name: "unknown priority",
+			todo: &todo.ToDo{Title: testTitle, Priority: todo.Priority(9)},
+			msg:  "priority must be between P1 and P4",
+		},
+		{
+			name: "date-only due at not midnight",
+			todo: &todo.ToDo{Title: testTitle, DueAt: timestamppb.New(time.Date(2030, 1, 2, 15, 0, 0, 0, time.UTC)), DueDateOnly: true},
+			msg:  "date-only due_at must be midnight UTC",
+		},
+		{
+			name: "relative reminder without due at",
+			todo: &todo.ToDo{Title: testTitle, ReminderBeforeDue: durationpb.New(time.Hour)},
+			msg:  "reminder_before_due requires due_at",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := srv.Create(context.Background(), &todo.CreateToDoRequest{ToDo: tc.todo})
+
+			assert.Nil(t, res)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Contains(t, err.Error(), tc.msg)
+		})
+	}
+}
+
+func TestReadToDoOverdue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	dueAt := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta(readQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, time.Now(), dueAt, false, 2, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(checklistQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "checked", "position"}))
+
+	res, err := srv.Read(context.Background(), &todo.ReadToDoRequest{Id: 1})
+
+	assert.NoError(t, err)
+	assert.True(t, res.ToDo.Overdue)
+	assert.Equal(t, todo.Priority_PRIORITY_P2, res.ToDo.Priority)
+	assert.Equal(t, dueAt.Unix(), res.ToDo.DueAt.AsTime().Unix())
+}
+
+func TestReadAllToDoFilterAndSort(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	dueBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND priority IN (?, ?) AND due_at < ? ORDER BY priority = 0, priority DESC, id"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(1, 2, dueBefore).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, "Dummy Todo 1", "Description 1", time.Now(), dueBefore.Add(-time.Hour), false, 1, nil, 0, "V", nil, 1, 0, 0, false, nil))
+
+	req := &todo.ReadAllToDoRequest{
+		Filter: &todo.ToDoFilter{
+			Priorities: []todo.Priority{todo.Priority_PRIORITY_P1, todo.Priority_PRIORITY_P2},
+			DueBefore:  timestamppb.New(dueBefore),
+		},
+		SortBy:     todo.SortField_SORT_FIELD_PRIORITY,
+		Descending: true,
+	}
+	res, err := svc.ReadAll(context.Background(), req)
+
+	assert.NoError(t, err)
+	assert.Len(t, res.ToDo, 1)
+	assert.False(t, res.ToDo[0].Overdue)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateToDoSchedulesReminder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	reminder := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+	req := &todo.CreateToDoRequest{
+		ToDo: &todo.ToDo{
+			Title:    testTitle,
+			Reminder: timestamppb.New(reminder),
+		},
+	}
+
+	mock.ExpectBegin()
+	expectLastRank(mock, 0, "")
+	mock.ExpectExec("INSERT INTO todo").
+		WithArgs(testTitle, "", reminder, nil, false, 0, nil, 0, "V").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO notification").
+		WithArgs("alice", "reminder", 1, testTitle, reminder).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 1, toDoFields...)
+	expectRecordChange(mock, 1, "created")
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-user-id", "alice"))
+	res, err := srv.Create(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), res.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
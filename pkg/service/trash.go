@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/blob"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *toDoServiceServer) ListTrash(ctx context.Context, req *todo.ListTrashRequest) (*todo.ListTrashResponse, error) {
+	now := time.Now()
+
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id"
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve trash: "+err.Error())
+	}
+	defer rows.Close()
+
+	var todos []*todo.ToDo
+	for rows.Next() {
+		t, err := scanToDo(rows, now)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+
+		todos = append(todos, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve trash: "+err.Error())
+	}
+
+	return &todo.ListTrashResponse{
+		ToDo: todos,
+	}, nil
+}
+
+func (s *toDoServiceServer) Restore(ctx context.Context, req *todo.RestoreRequest) (*todo.RestoreResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "todo id is required")
+	}
+
+	ctx, undo, err := s.withUndo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.applyInTx(ctx, func(tx *sql.Tx) error {
+		return restoreToDo(ctx, tx, req.GetId(), time.Now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.events.Publish()
+
+	return &todo.RestoreResponse{
+		Success:   true,
+		UndoToken: undo.Token(),
+	}, nil
+}
+
+// restoreToDo takes a todo out of the trash, stamping the restore with at.
+func restoreToDo(ctx context.Context, tx *sql.Tx, id int64, at time.Time) error {
+	res, err := tx.ExecContext(ctx, "UPDATE todo SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to restore todo: "+err.Error())
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return status.Error(codes.Internal, "failed to retrieve affected rows: "+err.Error())
+	}
+	if rowsAffected == 0 {
+		return status.Error(codes.NotFound, "todo not found in trash")
+	}
+
+	if err := stampFields(ctx, tx, id, clockAt(at, fieldDeleted)); err != nil {
+		return err
+	}
+
+	return recordChange(ctx, tx, id, event.KindUpdated)
+}
+
+func (s *toDoServiceServer) PurgeTrash(ctx context.Context, req *todo.PurgeTrashRequest) (*todo.PurgeTrashResponse, error) {
+	cutoff := time.Now()
+	if req.GetOlderThan() != nil {
+		if err := req.GetOlderThan().CheckValid(); err != nil || req.GetOlderThan().AsDuration() <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "older_than must be a positive duration")
+		}
+		cutoff = cutoff.Add(-req.GetOlderThan().AsDuration())
+	}
+
+	purged, err := purgeTrash(ctx, s.db, s.blobs, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo.PurgeTrashResponse{
+		Purged: purged,
+	}, nil
+}
+
+// purgeTrash permanently deletes the todos trashed at or before cutoff along
+// with their pending notifications and the attachment blobs nothing else
+// references. Checklists, comments and attachment rows go with the todo
+// through ON DELETE CASCADE.
+func purgeTrash(ctx context.Context, db *sql.DB, blobs blob.Store, cutoff time.Time) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	var sums []string
+	if blobs != nil {
+		if sums, err = trashedBlobs(ctx, tx, cutoff); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE n FROM notification n JOIN todo t ON t.id = n.todo_id WHERE t.deleted_at IS NOT NULL AND t.deleted_at <= ?", cutoff)
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to delete notifications: "+err.Error())
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM todo WHERE deleted_at IS NOT NULL AND deleted_at <= ?", cutoff)
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to purge trash: "+err.Error())
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, status.Error(codes.Internal, "failed to retrieve affected rows: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	if len(sums) > 0 {
+		deleteOrphanBlobs(ctx, db, blobs, sums)
+	}
+
+	return purged, nil
+}
+
+// TrashPurger periodically purges todos that have been in the trash longer
+// than the retention period.
+type TrashPurger struct {
+	db        *sql.DB
+	blobs     blob.Store
+	retention time.Duration
+	interval  time.Duration
+}
+
+// NewTrashPurger creates a purger; blobs may be nil when attachments are
+// disabled.
+func NewTrashPurger(db *sql.DB, blobs blob.Store, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		db:        db,
+		blobs:     blobs,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges the trash every interval until ctx is done. A failed purge is
+// reported to onError, which may be nil, and retried on the next tick.
+func (p *TrashPurger) Run(ctx context.Context, onError func(error)) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := purgeTrash(ctx, p.db, p.blobs, time.Now().Add(-p.retention)); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestListTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	deletedAt := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id")).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, time.Now(), nil, false, 0, nil, 0, "V", deletedAt, 1, 0, 0, false, nil))
+
+	res, err := srv.ListTrash(context.Background(), &todo.ListTrashRequest{})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.ToDo, 1)
+	assert.Equal(t, deletedAt.Unix(), res.ToDo[0].DeletedAt.AsTime().Unix())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadTrashedToDo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ?")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, time.Now(), nil, false, 0, nil, 0, "V", time.Now(), 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(checklistQuery)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "checked", "position"}))
+
+	res, err := srv.Read(context.Background(), &todo.ReadToDoRequest{Id: 1, IncludeTrashed: true})
+
+	assert.NoError(t, err)
+	assert.NotNil(t, res.ToDo.DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreToDo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	restoreQuery := regexp.QuoteMeta("UPDATE todo SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL")
+	mock.ExpectBegin()
+	mock.ExpectExec(restoreQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, 1, "deleted")
+	expectRecordChange(mock, 1, "updated")
+	expectUndoEntry(mock, 1)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(restoreQuery).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	res, err := srv.Restore(context.Background(), &todo.RestoreRequest{Id: 1})
+	assert.NoError(t, err)
+	assert.True(t, res.Success)
+
+	res, err = srv.Restore(context.Background(), &todo.RestoreRequest{Id: 2})
+	assert.Nil(t, res)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeTrashOlderThan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	// without a blob store no attachment lookup is needed
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE n FROM notification n")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM todo WHERE deleted_at IS NOT NULL AND deleted_at <= ?")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	res, err := srv.PurgeTrash(context.Background(), &todo.PurgeTrashRequest{OlderThan: durationpb.New(30 * 24 * time.Hour)})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), res.Purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeTrashNonPositiveDuration(t *testing.T) {
+	db, _, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	for _, d := range []time.Duration{-time.Hour, 0} {
+		res, err := srv.PurgeTrash(context.Background(), &todo.PurgeTrashRequest{OlderThan: durationpb.New(d)})
+
+		assert.Nil(t, res)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	}
+}
+
+func TestTrashPurgerReportsErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var reported error
+	err = service.NewTrashPurger(db, nil, time.Hour, time.Hour).Run(ctx, func(err error) {
+		reported = err
+		cancel()
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, reported.Error(), "connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
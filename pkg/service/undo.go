@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// undoEntry is how a todo looked before a call changed it.
+type undoEntry struct {
+	id int64
+	// snapshot is the last revision before the call, nil when the call
+	// created the todo
+	snapshot []byte
+	// version is the version the call left the todo at
+	version int64
+}
+
+// undoLog collects the todos changed by a call that can be undone. Entries
+// are written to undo_entry by the transaction that made the change, so the
+// failed items of a best-effort batch are left out.
+type undoLog struct {
+	token     string
+	principal string
+	expiresAt time.Time
+
+	pending []undoEntry
+	saved   bool
+}
+
+type undoLogKey struct{}
+
+// withUndo returns a context in which the changes made to todos are
+// recorded in a new undo log.
+func (s *toDoServiceServer) withUndo(ctx context.Context) (context.Context, *undoLog, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, status.Error(codes.Internal, "failed to generate undo token: "+err.Error())
+	}
+
+	l := &undoLog{
+		token:     hex.EncodeToString(b),
+		principal: util.PrincipalFromContext(ctx),
+		expiresAt: time.Now().Add(s.undoWindow),
+	}
+
+	return context.WithValue(ctx, undoLogKey{}, l), l, nil
+}
+
+func undoLogFrom(ctx context.Context) *undoLog {
+	l, _ := ctx.Value(undoLogKey{}).(*undoLog)
+	return l
+}
+
+// trackUndo notes a change to a todo when ctx carries an undo log. Only the
+// first snapshot of a todo matters, its version is that of the last change.
+func trackUndo(ctx context.Context, id int64, snapshot []byte, version int64) {
+	l := undoLogFrom(ctx)
+	if l == nil {
+		return
+	}
+
+	for i := range l.pending {
+		if l.pending[i].id == id {
+			l.pending[i].version = version
+			return
+		}
+	}
+
+	l.pending = append(l.pending, undoEntry{id: id, snapshot: snapshot, version: version})
+}
+
+// flushUndo writes the changes tracked in the current transaction of ctx.
+// It must run in that transaction, just before it commits.
+func flushUndo(ctx context.Context, tx *sql.Tx) error {
+	l := undoLogFrom(ctx)
+	if l == nil {
+		return nil
+	}
+
+	for _, e := range l.pending {
+		var snapshot interface{}
+		if e.snapshot != nil {
+			snapshot = e.snapshot
+		}
+
+		// a todo changed again by a later transaction of the same call keeps
+		// the snapshot from before the first change
+		_, err := tx.ExecContext(ctx, "INSERT INTO undo_entry(`token`, `todo_id`, `principal`, `version`, `snapshot`, `expires_at`) VALUES (?, ?, ?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE version = VALUES(version)",
+			l.token, e.id, l.principal, e.version, snapshot, l.expiresAt)
+		if err != nil {
+			return status.Error(codes.Internal, "failed to insert into undo_entry: "+err.Error())
+		}
+	}
+
+	l.saved = l.saved || len(l.pending) > 0
+	l.pending = nil
+
+	return nil
+}
+
+// discard drops the changes tracked in a transaction that rolled back.
+func (l *undoLog) discard() {
+	if l != nil {
+		l.pending = nil
+	}
+}
+
+// Token returns the token undoing the call, or "" when the call committed no
+// change.
+func (l *undoLog) Token() string {
+	if !l.saved {
+		return ""
+	}
+
+	return l.token
+}
+
+func (s *toDoServiceServer) Undo(ctx context.Context, req *todo.UndoRequest) (*todo.UndoResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "undo token is required")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	rows, err := tx.QueryContext(ctx, "SELECT todo_id, version, snapshot FROM undo_entry WHERE token = ? AND principal = ? AND expires_at > ? ORDER BY todo_id FOR UPDATE",
+		req.GetToken(), util.PrincipalFromContext(ctx), now)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve undo token: "+err.Error())
+	}
+
+	var entries []undoEntry
+	for rows.Next() {
+		var e undoEntry
+		if err := rows.Scan(&e.id, &e.version, &e.snapshot); err != nil {
+			rows.Close()
+			return nil, status.Error(codes.Internal, "failed to scan undo entry: "+err.Error())
+		}
+
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve undo token: "+err.Error())
+	}
+
+	if len(entries) == 0 {
+		return nil, status.Error(codes.NotFound, "undo token not found or expired")
+	}
+
+	for _, e := range entries {
+		current, err := scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ? FOR UPDATE", e.id), now)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+		}
+
+		if current.GetVersion() != e.version {
+			return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf("todo %d has been changed since", e.id))
+		}
+
+		// undoing a create moves the todo to the trash
+		if e.snapshot == nil {
+			if err := trashToDo(ctx, tx, e.id, 0, now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		target := &todo.ToDo{}
+		if err := protojson.Unmarshal(e.snapshot, target); err != nil {
+			return nil, status.Error(codes.Internal, "failed to decode undo entry: "+err.Error())
+		}
+		if err := revertToDo(ctx, tx, current, target, now); err != nil {
+			return nil, err
+		}
+	}
+
+	// a token is good for one undo
+	if _, err := tx.ExecContext(ctx, "DELETE FROM undo_entry WHERE token = ?", req.GetToken()); err != nil {
+		return nil, status.Error(codes.Internal, "failed to delete undo token: "+err.Error())
+	}
+
+	todos := make([]*todo.ToDo, len(entries))
+	for i, e := range entries {
+		if todos[i], err = scanToDo(tx.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ?", e.id), now); err != nil {
+			return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+	s.events.Publish()
+
+	return &todo.UndoResponse{
+		ToDos: todos,
+	}, nil
+}
+
+// PurgeUndoEntries deletes the undo entries that expired before now and
+// returns how many were deleted.
+func PurgeUndoEntries(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM undo_entry WHERE expires_at <= ?", now)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const undoSelectQuery = "SELECT todo_id, version, snapshot FROM undo_entry WHERE token = ? AND principal = ? AND expires_at > ? ORDER BY todo_id FOR UPDATE"
+
+const lockToDoRowQuery = "SELECT " + toDoColumns + " FROM todo WHERE id = ? FOR UPDATE"
+
+func TestUndoBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+	reminder := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	deletedAt := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(undoSelectQuery)).WithArgs("abc", "alice", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "version", "snapshot"}).
+			AddRow(1, 3, nil).
+			AddRow(2, 5, `{"title": "Draft", "description": "Old", "reminder": "2030-01-01T09:00:00Z"}`))
+
+	// todo 1 was created by the call and goes to the trash
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoRowQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, reminder, nil, false, 0, nil, 0, "V", nil, 3, 0, 0, false, nil))
+	mock.ExpectExec(regexp.QuoteMeta(trashQuery)).WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, 1, "deleted")
+	expectRecordChange(mock, 1, "deleted")
+
+	// todo 2 was edited and trashed by the call and comes back as it was
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoRowQuery)).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(2, testTitle, testDescription, reminder, nil, false, 0, nil, 0, "l", deletedAt, 5, 0, 0, false, nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE todo SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL")).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectStampFields(mock, 2, "deleted")
+	expectRecordChange(mock, 2, "updated")
+	expectPreviousReminder(mock, 2, reminder.Add(time.Hour))
+	mock.ExpectExec(regexp.QuoteMeta(updateQuery)).
+		WithArgs("Draft", "Old", reminder, nil, false, 0, nil, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM todo WHERE id = ?")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(7))
+	mock.ExpectExec(regexp.QuoteMeta(cancelReminderQuery)).WithArgs(2, "reminder").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO notification")).WithArgs("alice", "reminder", 2, "Draft", reminder).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectStampFields(mock, 2, toDoFields...)
+	expectRecordChange(mock, 2, "updated")
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM undo_entry WHERE token = ?")).WithArgs("abc").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ?")).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, reminder, nil, false, 0, nil, 0, "V", deletedAt, 4, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT " + toDoColumns + " FROM todo WHERE id = ?")).WithArgs(2).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(2, "Draft", "Old", reminder, nil, false, 0, nil, 0, "l", nil, 7, 0, 0, false, nil))
+	mock.ExpectCommit()
+
+	res, err := svc.Undo(asUser("alice"), &todo.UndoRequest{Token: "abc"})
+
+	assert.NoError(t, err)
+	assert.Len(t, res.ToDos, 2)
+	assert.NotNil(t, res.ToDos[0].DeletedAt)
+	assert.Nil(t, res.ToDos[1].DeletedAt)
+	assert.Equal(t, "Draft", res.ToDos[1].Title)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUndoChangedSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(undoSelectQuery)).WithArgs("abc", "alice", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "version", "snapshot"}).AddRow(1, 3, `{"title": "Draft"}`))
+	mock.ExpectQuery(regexp.QuoteMeta(lockToDoRowQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, time.Now(), nil, false, 0, nil, 0, "V", nil, 4, 0, 0, false, nil))
+	mock.ExpectRollback()
+
+	_, err = svc.Undo(asUser("alice"), &todo.UndoRequest{Token: "abc"})
+
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUndoExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	svc := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(undoSelectQuery)).WithArgs("abc", "bob", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"todo_id", "version", "snapshot"}))
+	mock.ExpectRollback()
+
+	_, err = svc.Undo(asUser("bob"), &todo.UndoRequest{Token: "abc"})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
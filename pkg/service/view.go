@@ -0,0 +1,504 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const viewColumns = "id, name, query, sort_by, descending, group_by, created_at, updated_at"
+
+// groupNone is the key of the group of todos without the grouped value.
+const groupNone = "none"
+
+// systemView is a built-in view. Its query is built when the view is used so
+// that it can refer to days in the caller's time zone.
+type systemView struct {
+	key     string
+	name    string
+	query   func(now time.Time) string
+	sortBy  todo.SortField
+	groupBy todo.ViewGrouping
+}
+
+var systemViews = []systemView{
+	{
+		key:     "today",
+		name:    "Today",
+		query:   func(time.Time) string { return "due:<=today" },
+		sortBy:  todo.SortField_SORT_FIELD_DUE_AT,
+		groupBy: todo.ViewGrouping_VIEW_GROUPING_LIST,
+	},
+	{
+		key:  "this_week",
+		name: "This week",
+		query: func(now time.Time) string {
+			// weeks start on Monday
+			days := (8 - int(now.Weekday())) % 7
+			if days == 0 {
+				days = 7
+			}
+			return "due:<" + now.AddDate(0, 0, days).Format(time.DateOnly)
+		},
+		sortBy:  todo.SortField_SORT_FIELD_DUE_AT,
+		groupBy: todo.ViewGrouping_VIEW_GROUPING_DUE_DATE,
+	},
+	{
+		key:    "overdue",
+		name:   "Overdue",
+		query:  func(time.Time) string { return "overdue" },
+		sortBy: todo.SortField_SORT_FIELD_DUE_AT,
+	},
+	{
+		key:     "no_due_date",
+		name:    "No due date",
+		query:   func(time.Time) string { return "due:none" },
+		groupBy: todo.ViewGrouping_VIEW_GROUPING_LIST,
+	},
+	{
+		key:     "high_priority",
+		name:    "High priority",
+		query:   func(time.Time) string { return "priority:<=2" },
+		sortBy:  todo.SortField_SORT_FIELD_PRIORITY,
+		groupBy: todo.ViewGrouping_VIEW_GROUPING_PRIORITY,
+	},
+}
+
+func (v systemView) view(now time.Time) *todo.SavedView {
+	return &todo.SavedView{
+		Name:      v.name,
+		Query:     v.query(now),
+		SortBy:    v.sortBy,
+		GroupBy:   v.groupBy,
+		SystemKey: v.key,
+	}
+}
+
+func findSystemView(key string) (systemView, bool) {
+	for _, v := range systemViews {
+		if v.key == key {
+			return v, true
+		}
+	}
+
+	return systemView{}, false
+}
+
+func validateView(v *todo.SavedView, ft fullText) error {
+	if v == nil {
+		return status.Error(codes.InvalidArgument, "view is required")
+	}
+
+	if err := util.ValidateViewName(v.GetName()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if _, ok := todo.SortField_name[int32(v.GetSortBy())]; !ok {
+		return status.Error(codes.InvalidArgument, "unknown sort_by")
+	}
+
+	if _, ok := todo.ViewGrouping_name[int32(v.GetGroupBy())]; !ok {
+		return status.Error(codes.InvalidArgument, "unknown group_by")
+	}
+
+	if v.GetSystemKey() != "" {
+		return status.Error(codes.InvalidArgument, "system views cannot be changed")
+	}
+
+	// the query is compiled again on every run, but errors belong here
+	var where whereClause
+	_, err := applyQuery(&where, ft, v.GetQuery(), time.Now())
+	return err
+}
+
+func scanView(row rowScanner) (*todo.SavedView, error) {
+	var (
+		v                    todo.SavedView
+		sortBy, groupBy      int32
+		createdAt, updatedAt time.Time
+	)
+
+	if err := row.Scan(&v.Id, &v.Name, &v.Query, &sortBy, &v.Descending, &groupBy, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	v.SortBy = todo.SortField(sortBy)
+	v.GroupBy = todo.ViewGrouping(groupBy)
+	v.CreatedAt = timestamppb.New(createdAt)
+	v.UpdatedAt = timestamppb.New(updatedAt)
+
+	return &v, nil
+}
+
+// loadView returns one of owner's views, locking it when lock is set.
+func loadView(ctx context.Context, db rowQueryer, id int64, owner string, lock bool) (*todo.SavedView, error) {
+	query := "SELECT " + viewColumns + " FROM saved_view WHERE id = ? AND owner = ?"
+	if lock {
+		query += " FOR UPDATE"
+	}
+
+	v, err := scanView(db.QueryRowContext(ctx, query, id, owner))
+	if err == sql.ErrNoRows {
+		return nil, status.Error(codes.NotFound, "view not found")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve view: "+err.Error())
+	}
+
+	return v, nil
+}
+
+// checkViewName fails when owner has another view called name. Two calls
+// racing for the same name can both pass it, so the write is still checked
+// against the unique key with viewNameTaken.
+func checkViewName(ctx context.Context, tx *sql.Tx, owner, name string, id int64) error {
+	var count int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM saved_view WHERE owner = ? AND name = ? AND id <> ?", owner, name, id).Scan(&count)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to check view name: "+err.Error())
+	}
+
+	if count > 0 {
+		return viewNameTaken(name)
+	}
+
+	return nil
+}
+
+// viewNameTaken reports a write that lost the race for name.
+func viewNameTaken(name string) error {
+	return status.Error(codes.AlreadyExists, fmt.Sprintf("a view called %q already exists", name))
+}
+
+func (s *toDoServiceServer) CreateView(ctx context.Context, req *todo.CreateViewRequest) (*todo.CreateViewResponse, error) {
+	owner, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v := req.GetView()
+	if err := validateView(v, s.fullText); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	if err := checkViewName(ctx, tx, owner, v.GetName(), 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res, err := tx.ExecContext(ctx, "INSERT INTO saved_view(`owner`, `name`, `query`, `sort_by`, `descending`, `group_by`, `created_at`, `updated_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		owner, v.GetName(), v.GetQuery(), int32(v.GetSortBy()), v.GetDescending(), int32(v.GetGroupBy()), now, now)
+	if isDuplicateKey(err) {
+		return nil, viewNameTaken(v.GetName())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to insert into saved_view: "+err.Error())
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve id for created view: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	return &todo.CreateViewResponse{
+		View: &todo.SavedView{
+			Id:         id,
+			Name:       v.GetName(),
+			Query:      v.GetQuery(),
+			SortBy:     v.GetSortBy(),
+			Descending: v.GetDescending(),
+			GroupBy:    v.GetGroupBy(),
+			CreatedAt:  timestamppb.New(now),
+			UpdatedAt:  timestamppb.New(now),
+		},
+	}, nil
+}
+
+func (s *toDoServiceServer) GetView(ctx context.Context, req *todo.GetViewRequest) (*todo.GetViewResponse, error) {
+	owner, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "view id is required")
+	}
+
+	v, err := loadView(ctx, s.db, req.GetId(), owner, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo.GetViewResponse{
+		View: v,
+	}, nil
+}
+
+func (s *toDoServiceServer) UpdateView(ctx context.Context, req *todo.UpdateViewRequest) (*todo.UpdateViewResponse, error) {
+	owner, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	v := req.GetView()
+	if v.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "view id is required")
+	}
+
+	if err := validateView(v, s.fullText); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin transaction: "+err.Error())
+	}
+	defer tx.Rollback()
+
+	current, err := loadView(ctx, tx, v.GetId(), owner, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkViewName(ctx, tx, owner, v.GetName(), v.GetId()); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, "UPDATE saved_view SET name = ?, query = ?, sort_by = ?, descending = ?, group_by = ?, updated_at = ? WHERE id = ?",
+		v.GetName(), v.GetQuery(), int32(v.GetSortBy()), v.GetDescending(), int32(v.GetGroupBy()), now, v.GetId())
+	if isDuplicateKey(err) {
+		return nil, viewNameTaken(v.GetName())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to update view: "+err.Error())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to commit transaction: "+err.Error())
+	}
+
+	current.Name = v.GetName()
+	current.Query = v.GetQuery()
+	current.SortBy = v.GetSortBy()
+	current.Descending = v.GetDescending()
+	current.GroupBy = v.GetGroupBy()
+	current.UpdatedAt = timestamppb.New(now)
+
+	return &todo.UpdateViewResponse{
+		View: current,
+	}, nil
+}
+
+func (s *toDoServiceServer) DeleteView(ctx context.Context, req *todo.DeleteViewRequest) (*todo.DeleteViewResponse, error) {
+	owner, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "view id is required")
+	}
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM saved_view WHERE id = ? AND owner = ?", req.GetId(), owner)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to delete view: "+err.Error())
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve affected rows: "+err.Error())
+	}
+	if rowsAffected == 0 {
+		return nil, status.Error(codes.NotFound, "view not found")
+	}
+
+	return &todo.DeleteViewResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *toDoServiceServer) ListViews(ctx context.Context, req *todo.ListViewsRequest) (*todo.ListViewsResponse, error) {
+	owner, err := requirePrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := util.Location(ctx, "")
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	now := time.Now().In(loc)
+	var views []*todo.SavedView
+	for _, v := range systemViews {
+		views = append(views, v.view(now))
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT "+viewColumns+" FROM saved_view WHERE owner = ? ORDER BY name", owner)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve views: "+err.Error())
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		v, err := scanView(rows)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan view: "+err.Error())
+		}
+		views = append(views, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve views: "+err.Error())
+	}
+
+	return &todo.ListViewsResponse{
+		Views: views,
+	}, nil
+}
+
+func (s *toDoServiceServer) ExecuteView(ctx context.Context, req *todo.ExecuteViewRequest) (*todo.ExecuteViewResponse, error) {
+	loc, err := util.Location(ctx, req.GetTimeZone())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	// relative dates in the query are resolved in the location of now
+	now := time.Now().In(loc)
+
+	var v *todo.SavedView
+	switch {
+	case req.GetSystemKey() != "":
+		sv, ok := findSystemView(req.GetSystemKey())
+		if !ok {
+			return nil, status.Error(codes.NotFound, "unknown system view "+req.GetSystemKey())
+		}
+		v = sv.view(now)
+	case req.GetId() != 0:
+		owner, err := requirePrincipal(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = loadView(ctx, s.db, req.GetId(), owner, false); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "view id or system_key is required")
+	}
+
+	query, args, err := readAllQuery(&todo.ReadAllToDoRequest{
+		Query:      v.GetQuery(),
+		SortBy:     v.GetSortBy(),
+		Descending: v.GetDescending(),
+	}, s.fullText, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+	defer rows.Close()
+
+	var todos []*todo.ToDo
+	for rows.Next() {
+		t, err := scanToDo(rows, now)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan todo item: "+err.Error())
+		}
+		todos = append(todos, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve todos: "+err.Error())
+	}
+
+	return &todo.ExecuteViewResponse{
+		View:   v,
+		Groups: groupToDos(todos, v.GetGroupBy(), loc),
+	}, nil
+}
+
+// groupToDos splits todos into groups, keeping their order within each
+// group. Groups are ordered by key with none last.
+func groupToDos(todos []*todo.ToDo, by todo.ViewGrouping, loc *time.Location) []*todo.ViewGroup {
+	if by == todo.ViewGrouping_VIEW_GROUPING_UNSPECIFIED {
+		return []*todo.ViewGroup{{ToDo: todos}}
+	}
+
+	var groups []*todo.ViewGroup
+	order := make(map[*todo.ViewGroup]string)
+	byKey := make(map[string]*todo.ViewGroup)
+	for _, t := range todos {
+		key, sortKey := groupKey(t, by, loc)
+		g, ok := byKey[key]
+		if !ok {
+			g = &todo.ViewGroup{Key: key}
+			byKey[key] = g
+			order[g] = sortKey
+			groups = append(groups, g)
+		}
+		g.ToDo = append(g.ToDo, t)
+	}
+
+	slices.SortFunc(groups, func(a, b *todo.ViewGroup) int {
+		switch {
+		case order[a] < order[b]:
+			return -1
+		case order[a] > order[b]:
+			return 1
+		}
+		return 0
+	})
+
+	return groups
+}
+
+// groupKey returns the group of t and a key that sorts groups, with none
+// sorting last.
+func groupKey(t *todo.ToDo, by todo.ViewGrouping, loc *time.Location) (string, string) {
+	const last = "~"
+
+	switch by {
+	case todo.ViewGrouping_VIEW_GROUPING_LIST:
+		return strconv.FormatInt(t.GetListId(), 10), fmt.Sprintf("%020d", t.GetListId())
+	case todo.ViewGrouping_VIEW_GROUPING_PRIORITY:
+		if t.GetPriority() == todo.Priority_PRIORITY_UNSPECIFIED {
+			return groupNone, last
+		}
+		key := fmt.Sprintf("P%d", t.GetPriority())
+		return key, key
+	default:
+		if t.GetDueAt() == nil {
+			return groupNone, last
+		}
+		// a date-only due date is the same day everywhere
+		due := t.GetDueAt().AsTime()
+		if !t.GetDueDateOnly() {
+			due = due.In(loc)
+		}
+		key := due.Format(time.DateOnly)
+		return key, key
+	}
+}
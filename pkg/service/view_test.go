@@ -0,0 +1,196 @@
+package service_test
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const viewNameQuery = "SELECT COUNT(*) FROM saved_view WHERE owner = ? AND name = ? AND id <> ?"
+
+func TestCreateView(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	view := &todo.SavedView{
+		Name:    "Work this week",
+		Query:   "list:3 due:<7d",
+		SortBy:  todo.SortField_SORT_FIELD_DUE_AT,
+		GroupBy: todo.ViewGrouping_VIEW_GROUPING_PRIORITY,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(viewNameQuery)).WithArgs("alice", view.Name, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO saved_view(`owner`, `name`, `query`, `sort_by`, `descending`, `group_by`, `created_at`, `updated_at`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")).
+		WithArgs("alice", view.Name, view.Query, 1, false, 2, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	res, err := srv.CreateView(asUser("alice"), &todo.CreateViewRequest{View: view})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), res.View.Id)
+	assert.Equal(t, view.Query, res.View.Query)
+	assert.NotNil(t, res.View.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateViewDuplicateName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(viewNameQuery)).WithArgs("alice", "Work", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	_, err = srv.CreateView(asUser("alice"), &todo.CreateViewRequest{View: &todo.SavedView{Name: "Work", Query: "list:3"}})
+
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateViewLosesRaceForName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	// another call creates "Work" between the check and the insert
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(viewNameQuery)).WithArgs("alice", "Work", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO saved_view")).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice-Work' for key 'saved_view_owner_name_idx'"})
+	mock.ExpectRollback()
+
+	_, err = srv.CreateView(asUser("alice"), &todo.CreateViewRequest{View: &todo.SavedView{Name: "Work", Query: "list:3"}})
+
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateViewInvalidQuery(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	_, err = srv.CreateView(asUser("alice"), &todo.CreateViewRequest{View: &todo.SavedView{Name: "Work", Query: "color:red"}})
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Len(t, st.Details(), 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteSystemViewInTimeZone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	ctx := metadata.NewIncomingContext(asUser("alice"), metadata.Pairs("x-time-zone", "Asia/Jakarta"))
+
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND " +
+		"((due_date_only = FALSE AND due_at < ?) OR (due_date_only = TRUE AND due_at < ?)) ORDER BY due_at IS NULL, due_at ASC, id"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(3, "third", testDescription, time.Now(), time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC), false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil).
+			// past 17:00 UTC it is already the next day in Jakarta
+			AddRow(1, "first", testDescription, time.Now(), time.Date(2030, 1, 1, 20, 0, 0, 0, time.UTC), false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil).
+			AddRow(2, "second", testDescription, time.Now(), time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC), true, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+
+	res, err := srv.ExecuteView(ctx, &todo.ExecuteViewRequest{SystemKey: "this_week"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "This week", res.View.Name)
+	assert.Len(t, res.Groups, 2)
+	assert.Equal(t, "2030-01-01", res.Groups[0].Key)
+	assert.Len(t, res.Groups[0].ToDo, 1)
+	assert.Equal(t, "2030-01-02", res.Groups[1].Key)
+	assert.Len(t, res.Groups[1].ToDo, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// instant matches a time argument by the instant it stands for
+type instant time.Time
+
+func (i instant) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	return ok && t.Equal(time.Time(i))
+}
+
+func TestExecuteTodayViewComparesDateOnlyTodosByDate(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	ctx := metadata.NewIncomingContext(asUser("alice"), metadata.Pairs("x-time-zone", "Asia/Jakarta"))
+
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	assert.NoError(t, err)
+	y, m, d := time.Now().In(jakarta).Date()
+
+	// timed todos are due before midnight in Jakarta, and date-only todos,
+	// stored as midnight UTC, on any date up to today's date there
+	query := "SELECT " + toDoColumns + " FROM todo WHERE deleted_at IS NULL AND " +
+		"((due_date_only = FALSE AND due_at < ?) OR (due_date_only = TRUE AND due_at < ?))"
+	mock.ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(instant(time.Date(y, m, d+1, 0, 0, 0, 0, jakarta)), instant(time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC))).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns))
+
+	_, err = srv.ExecuteView(ctx, &todo.ExecuteViewRequest{SystemKey: "today"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteViewOfAnotherUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, query, sort_by, descending, group_by, created_at, updated_at FROM saved_view WHERE id = ? AND owner = ?")).
+		WithArgs(4, "bob").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "query", "sort_by", "descending", "group_by", "created_at", "updated_at"}))
+
+	_, err = srv.ExecuteView(asUser("bob"), &todo.ExecuteViewRequest{Id: 4})
+
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExecuteViewUnknownTimeZone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	_, err = srv.ExecuteView(asUser("alice"), &todo.ExecuteViewRequest{SystemKey: "today", TimeZone: "Mars/Olympus"})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
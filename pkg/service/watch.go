@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/event"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// watchPollInterval bounds how late a Watch stream sees the changes made
+	// through other server instances, which do not publish on this bus
+	watchPollInterval = 5 * time.Second
+	// watchBatchSize is how many events are read from the feed at a time
+	watchBatchSize = 500
+)
+
+var changeKinds = map[string]todo.ChangeKind{
+	event.KindCreated: todo.ChangeKind_CHANGE_KIND_CREATED,
+	event.KindUpdated: todo.ChangeKind_CHANGE_KIND_UPDATED,
+	event.KindDeleted: todo.ChangeKind_CHANGE_KIND_DELETED,
+}
+
+// recordChange adds a change to the feed read by Watch and to the revision
+// history of the todo. It must run in the transaction of the change.
+func recordChange(ctx context.Context, tx *sql.Tx, id int64, kind string) error {
+	if err := event.Record(ctx, tx, id, kind); err != nil {
+		return status.Error(codes.Internal, "failed to record change: "+err.Error())
+	}
+
+	return recordRevision(ctx, tx, id)
+}
+
+func (s *toDoServiceServer) Watch(req *todo.WatchRequest, stream grpc.ServerStreamingServer[todo.WatchResponse]) error {
+	if req.GetSinceSequence() < 0 {
+		return status.Error(codes.InvalidArgument, "since_sequence cannot be negative")
+	}
+
+	ctx := stream.Context()
+
+	// subscribe before catching up so no commit slips in between
+	updates, cancel := s.events.Subscribe()
+	defer cancel()
+
+	last := req.GetSinceSequence()
+	if last == 0 {
+		var err error
+		if last, _, err = event.Sequences(ctx, s.db); err != nil {
+			return status.Error(codes.Internal, "failed to retrieve sequence: "+err.Error())
+		}
+	} else if err := checkResumable(ctx, s.db, last); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := event.Assign(ctx, s.db); err != nil {
+			return status.Error(codes.Internal, "failed to number events: "+err.Error())
+		}
+
+		var err error
+		if last, err = s.sendChanges(ctx, stream, last, req.ListId); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-updates:
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkResumable fails with OutOfRange when some of the events after
+// sequence last have already been purged from the feed.
+func checkResumable(ctx context.Context, db rowQueryer, last int64) error {
+	_, purged, err := event.Sequences(ctx, db)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to retrieve sequence: "+err.Error())
+	}
+	if last < purged {
+		return status.Error(codes.OutOfRange, fmt.Sprintf("events after sequence %d have expired, resync from scratch", last))
+	}
+
+	return nil
+}
+
+// sendChanges sends the events recorded after sequence last and returns the
+// sequence of the last event sent.
+func (s *toDoServiceServer) sendChanges(ctx context.Context, stream grpc.ServerStreamingServer[todo.WatchResponse], last int64, listID *int64) (int64, error) {
+	for {
+		events, err := loadEvents(ctx, s.db, last, listID, watchBatchSize)
+		if err != nil {
+			return last, err
+		}
+
+		for _, e := range events {
+			res, err := s.changeResponse(ctx, e)
+			if err != nil {
+				return last, err
+			}
+
+			if err := stream.Send(res); err != nil {
+				return last, err
+			}
+			last = e.Sequence
+		}
+
+		if len(events) < watchBatchSize {
+			return last, nil
+		}
+	}
+}
+
+// loadEvents reads up to limit events recorded after sequence after.
+func loadEvents(ctx context.Context, db queryer, after int64, listID *int64, limit int) ([]event.Event, error) {
+	var where whereClause
+	where.add("seq > ?", after)
+	if listID != nil {
+		where.add("list_id = ?", *listID)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT seq, todo_id, list_id, kind, created_at FROM todo_event"+where.String()+" ORDER BY seq LIMIT ?",
+		append(where.args, limit)...)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve events: "+err.Error())
+	}
+	defer rows.Close()
+
+	var events []event.Event
+	for rows.Next() {
+		var e event.Event
+		if err := rows.Scan(&e.Sequence, &e.ToDoID, &e.ListID, &e.Kind, &e.CreatedAt); err != nil {
+			return nil, status.Error(codes.Internal, "failed to scan event: "+err.Error())
+		}
+
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, status.Error(codes.Internal, "failed to retrieve events: "+err.Error())
+	}
+
+	return events, nil
+}
+
+// changeResponse builds the message for an event, with the todo as it is
+// now. A todo purged since the event is reported as deleted.
+func (s *toDoServiceServer) changeResponse(ctx context.Context, e event.Event) (*todo.WatchResponse, error) {
+	res := &todo.WatchResponse{
+		Sequence: e.Sequence,
+		Kind:     changeKinds[e.Kind],
+	}
+
+	if e.Kind != event.KindDeleted {
+		t, err := scanToDo(s.db.QueryRowContext(ctx, "SELECT "+toDoColumns+" FROM todo WHERE id = ?", e.ToDoID), time.Now())
+		if err == nil {
+			if t.Checklist, err = s.listChecklist(ctx, t.Id); err != nil {
+				return nil, err
+			}
+
+			res.ToDo = t
+			return res, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, status.Error(codes.Internal, "failed to retrive todo: "+err.Error())
+		}
+
+		res.Kind = todo.ChangeKind_CHANGE_KIND_DELETED
+	}
+
+	res.ToDo = &todo.ToDo{
+		Id:        e.ToDoID,
+		DeletedAt: timestamppb.New(e.CreatedAt),
+	}
+
+	return res, nil
+}
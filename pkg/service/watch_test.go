@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const watchReadQuery = "SELECT " + toDoColumns + " FROM todo WHERE id = ?"
+const eventsQuery = "SELECT seq, todo_id, list_id, kind, created_at FROM todo_event WHERE seq > ? ORDER BY seq LIMIT ?"
+
+const sequencesQuery = "SELECT last_seq, purged_seq FROM todo_event_sequence WHERE id = 1"
+
+// expectSequences mocks the lookup of the last sequence handed out and the
+// last one purged
+func expectSequences(mock sqlmock.Sqlmock, last, purged int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(sequencesQuery)).
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq", "purged_seq"}).AddRow(last, purged))
+}
+
+// expectAssign mocks numbering the events, with none waiting for a number
+func expectAssign(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT last_seq FROM todo_event_sequence WHERE id = 1 FOR UPDATE SKIP LOCKED")).
+		WillReturnRows(sqlmock.NewRows([]string{"last_seq"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM todo_event WHERE seq IS NULL ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+}
+
+// watchStream ends the stream once it has received want messages
+type watchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	want   int
+	sent   []*todo.WatchResponse
+}
+
+func newWatchStream(want int) *watchStream {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &watchStream{ctx: ctx, cancel: cancel, want: want}
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *watchStream) Send(res *todo.WatchResponse) error {
+	s.sent = append(s.sent, res)
+	if len(s.sent) == s.want {
+		s.cancel()
+	}
+	return nil
+}
+
+func TestWatchResumesFromSequence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+	now := time.Now()
+
+	expectSequences(mock, 7, 2)
+	expectAssign(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(eventsQuery)).WithArgs(4, 500).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "todo_id", "list_id", "kind", "created_at"}).
+			AddRow(5, 1, 0, "created", now).
+			AddRow(6, 2, 0, "deleted", now).
+			AddRow(7, 3, 0, "updated", now))
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns).
+			AddRow(1, testTitle, testDescription, now, nil, false, 0, nil, 0, "V", nil, 1, 0, 0, false, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(checklistQuery)).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "text", "checked", "position"}))
+	// todo 3 has been purged since it was updated
+	mock.ExpectQuery(regexp.QuoteMeta(watchReadQuery)).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(toDoRowColumns))
+
+	stream := newWatchStream(3)
+	err = srv.Watch(&todo.WatchRequest{SinceSequence: 4}, stream)
+
+	assert.NoError(t, err)
+	assert.Len(t, stream.sent, 3)
+	assert.Equal(t, todo.ChangeKind_CHANGE_KIND_CREATED, stream.sent[0].Kind)
+	assert.Equal(t, testTitle, stream.sent[0].ToDo.Title)
+	assert.Equal(t, todo.ChangeKind_CHANGE_KIND_DELETED, stream.sent[1].Kind)
+	assert.Equal(t, int64(2), stream.sent[1].ToDo.Id)
+	assert.NotNil(t, stream.sent[1].ToDo.DeletedAt)
+	assert.Equal(t, todo.ChangeKind_CHANGE_KIND_DELETED, stream.sent[2].Kind)
+	assert.Equal(t, int64(7), stream.sent[2].Sequence)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatchExpiredSequence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	// every event has been purged, up to sequence 50
+	expectSequences(mock, 50, 50)
+
+	err = srv.Watch(&todo.WatchRequest{SinceSequence: 10}, newWatchStream(1))
+
+	assert.Equal(t, codes.OutOfRange, status.Code(err))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatchResumesAcrossMissingSequence(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	srv := service.NewTodoServiceServer(db)
+
+	// sequence 5 was never committed, which must not read as expired
+	expectSequences(mock, 6, 0)
+	expectAssign(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(eventsQuery)).WithArgs(4, 500).
+		WillReturnRows(sqlmock.NewRows([]string{"seq", "todo_id", "list_id", "kind", "created_at"}).
+			AddRow(6, 2, 0, "deleted", time.Now()))
+
+	stream := newWatchStream(1)
+	err = srv.Watch(&todo.WatchRequest{SinceSequence: 4}, stream)
+
+	assert.NoError(t, err)
+	assert.Len(t, stream.sent, 1)
+	assert.Equal(t, int64(6), stream.sent[0].Sequence)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
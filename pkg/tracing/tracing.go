@@ -0,0 +1,85 @@
+// Package tracing traces calls to the API with OpenTelemetry. Every call gets
+// a span, continuing the W3C trace context sent by the client, with a child
+// span for every SQL statement run on its behalf, so a slow call shows
+// whether the time went into the network, a query or scanning its rows.
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+)
+
+// serviceName names the service in exported spans
+const serviceName = "simple-to-do-service"
+
+const (
+	// ExporterOTLP exports spans over OTLP/gRPC, e.g. to a local collector.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to stdout.
+	ExporterStdout = "stdout"
+)
+
+// Setup installs the global tracer provider, exporting spans with exporter,
+// and the W3C trace context propagator. The OTLP exporter sends to endpoint,
+// a host:port without TLS. With no exporter spans are not recorded but trace
+// contexts are still propagated. The returned function flushes the spans not
+// exported yet.
+func Setup(ctx context.Context, exporter, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exp, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure())
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// OpenDB opens a database like sql.Open whose statements are traced as
+// children of the span in their context.
+func OpenDB(driverName, dsn string) (*sql.DB, error) {
+	return otelsql.Open(driverName, dsn,
+		otelsql.WithAttributes(semconv.DBSystemMySQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			// a statement run without a prepared statement first tries the
+			// fast path, which the driver may skip
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+		}),
+	)
+}
+
+// ServerOption traces the calls handled by a gRPC server.
+func ServerOption() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler())
+}
@@ -0,0 +1,83 @@
+package tracing_test
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"github.com/ariefro/simple-to-do-service/pkg/service"
+	"github.com/ariefro/simple-to-do-service/pkg/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestCallIsTraced(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	_, err := tracing.Setup(context.Background(), "", "")
+	assert.NoError(t, err)
+
+	_, mock, err := sqlmock.NewWithDSN("tracing")
+	assert.NoError(t, err)
+	db, err := tracing.OpenDB("sqlmock", "tracing")
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM todo WHERE id = ?")).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(tracing.ServerOption())
+	todo.RegisterToDoServiceServer(server, service.NewTodoServiceServer(db))
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// the client sends its W3C trace context
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err = todo.NewToDoServiceClient(conn).Read(ctx, &todo.ReadToDoRequest{Id: 7})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	server.GracefulStop()
+
+	var call, query sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		switch s.Name() {
+		case "pb.ToDoService/Read":
+			call = s
+		case "sql.conn.query":
+			query = s
+		}
+	}
+
+	if assert.NotNil(t, call) && assert.NotNil(t, query) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", call.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", call.Parent().SpanID().String())
+		assert.Equal(t, trace.SpanKindServer, call.SpanKind())
+
+		assert.Equal(t, call.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Contains(t, query.Attributes(), attribute.String("db.system", "mysql"))
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,33 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// IdempotencyMetadataKey is the request metadata key that may carry an
+// idempotency key instead of the request field.
+const IdempotencyMetadataKey = "idempotency-key"
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyKey returns the idempotency key of a request: the request field
+// when set, otherwise the idempotency-key metadata, otherwise "".
+func IdempotencyKey(ctx context.Context, field string) (string, error) {
+	key := field
+	if key == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(IdempotencyMetadataKey); len(values) > 0 {
+				key = values[0]
+			}
+		}
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fmt.Errorf("idempotency key cannot be longer than %d bytes", maxIdempotencyKeyLength)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,27 @@
+package util
+
+import "regexp"
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// ParseMentions returns the distinct user names mentioned with @name in text,
+// in order of first appearance.
+func ParseMentions(text string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := match[1]
+		// a trailing dot or dash is punctuation, not part of the name
+		for len(name) > 0 && (name[len(name)-1] == '.' || name[len(name)-1] == '-') {
+			name = name[:len(name)-1]
+		}
+
+		if !seen[name] {
+			seen[name] = true
+			mentions = append(mentions, name)
+		}
+	}
+
+	return mentions
+}
@@ -0,0 +1,26 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text     string
+		mentions []string
+	}{
+		{"no mentions here", nil},
+		{"@alice please review", []string{"alice"}},
+		{"cc @bob, @carol.smith and @bob again.", []string{"bob", "carol.smith"}},
+		{"ping @dave.", []string{"dave"}},
+		{"mail me at erin@example.com", nil},
+		{"(@frank)", []string{"frank"}},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.mentions, util.ParseMentions(tc.text), tc.text)
+	}
+}
@@ -0,0 +1,31 @@
+package util
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// PrincipalMetadataKey is the request metadata key carrying the caller's
+// user id. The service does not authenticate callers and takes the id as
+// given: it must be deployed behind a proxy that authenticates them, sets
+// this key and drops any value sent by the client. Everything keyed on the
+// principal, including the audit admins and the principal recorded in the
+// audit log, is only as trustworthy as that proxy.
+const PrincipalMetadataKey = "x-user-id"
+
+// PrincipalFromContext returns the caller's user id from the incoming request
+// metadata, or "" when the caller did not identify itself.
+func PrincipalFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(PrincipalMetadataKey)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
@@ -0,0 +1,120 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rankDigits are the digits of rank keys, in ascending byte order so keys
+// compare correctly with plain string (and binary collation) comparison.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// RankBetween returns a rank key that sorts strictly between a and b. An
+// empty a means no lower bound and an empty b means no upper bound. Keys
+// never end in the smallest digit, which guarantees there is always room
+// for another key below any existing one.
+func RankBetween(a, b string) (string, error) {
+	if err := validateRank(a); err != nil {
+		return "", err
+	}
+	if err := validateRank(b); err != nil {
+		return "", err
+	}
+	if b != "" && a >= b {
+		return "", fmt.Errorf("rank %q must sort before %q", a, b)
+	}
+
+	return midpoint(a, b), nil
+}
+
+// EvenRanks returns n short, evenly spaced rank keys in ascending order.
+// It is used to rebalance a list whose keys have grown long.
+func EvenRanks(n int) []string {
+	base := len(rankDigits)
+
+	// pick the shortest key length that leaves a gap between every key
+	length, space := 1, base
+	for space <= n {
+		length++
+		space *= base
+	}
+
+	ranks := make([]string, n)
+	step := space / (n + 1)
+	for i := range ranks {
+		value := (i + 1) * step
+		key := make([]byte, length)
+		for j := length - 1; j >= 0; j-- {
+			key[j] = rankDigits[value%base]
+			value /= base
+		}
+		ranks[i] = strings.TrimRight(string(key), rankDigits[:1])
+	}
+
+	return ranks
+}
+
+func validateRank(key string) error {
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(rankDigits, key[i]) < 0 {
+			return fmt.Errorf("invalid rank %q", key)
+		}
+	}
+
+	if strings.HasSuffix(key, rankDigits[:1]) {
+		return fmt.Errorf("invalid rank %q", key)
+	}
+
+	return nil
+}
+
+// midpoint assumes a < b (or b is unbounded) and both keys are valid.
+func midpoint(a, b string) string {
+	if b != "" {
+		// keep the common prefix and find a midpoint in the remainder
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(rankDigits, a[0])
+	}
+	hi := len(rankDigits)
+	if b != "" {
+		hi = strings.IndexByte(rankDigits, b[0])
+	}
+
+	if hi-lo > 1 {
+		return string(rankDigits[(lo+hi+1)/2])
+	}
+
+	// the first digits are adjacent: a single digit b[0] already sorts
+	// between them if b continues past it
+	if b != "" && len(b) > 1 {
+		return b[:1]
+	}
+
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(rankDigits[lo]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+
+	return rankDigits[0]
+}
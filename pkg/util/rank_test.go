@@ -0,0 +1,74 @@
+package util_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"", "1"},
+		{"1", "1V"},
+		{"AV", "B"},
+		{"V", ""},
+		{"z", ""},
+		{"zzz", ""},
+		{"", "01"},
+		{"a", "a1"},
+	}
+
+	for _, tc := range tests {
+		key, err := util.RankBetween(tc.a, tc.b)
+		require.NoError(t, err)
+		assert.Greater(t, key, tc.a)
+		if tc.b != "" {
+			assert.Less(t, key, tc.b)
+		}
+		assert.NotEqual(t, byte('0'), key[len(key)-1])
+	}
+}
+
+func TestRankBetweenInvalid(t *testing.T) {
+	_, err := util.RankBetween("B", "A")
+	assert.Error(t, err)
+
+	_, err = util.RankBetween("A0", "")
+	assert.Error(t, err)
+
+	_, err = util.RankBetween("", "A-")
+	assert.Error(t, err)
+}
+
+func TestRankBetweenRepeatedInsertsStayOrdered(t *testing.T) {
+	// keep inserting at the front of the list, the worst case for key growth
+	keys := []string{"V"}
+	for i := 0; i < 200; i++ {
+		key, err := util.RankBetween("", keys[0])
+		require.NoError(t, err)
+		keys = append([]string{key}, keys...)
+	}
+
+	assert.True(t, sort.StringsAreSorted(keys))
+}
+
+func TestEvenRanks(t *testing.T) {
+	for _, n := range []int{1, 10, 61, 62, 1000} {
+		keys := util.EvenRanks(n)
+		require.Len(t, keys, n)
+		assert.True(t, sort.SliceIsSorted(keys, func(i, j int) bool { return keys[i] < keys[j] }))
+		for i := 1; i < n; i++ {
+			assert.NotEqual(t, keys[i-1], keys[i])
+		}
+		for _, key := range keys {
+			_, err := util.RankBetween(key, "")
+			assert.NoError(t, err)
+		}
+	}
+}
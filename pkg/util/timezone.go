@@ -0,0 +1,37 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// TimeZoneMetadataKey is the request metadata key that may carry the
+// caller's IANA time zone, such as Asia/Jakarta.
+const TimeZoneMetadataKey = "x-time-zone"
+
+// Location returns the caller's time zone: the request field when set,
+// otherwise the x-time-zone metadata, otherwise UTC.
+func Location(ctx context.Context, field string) (*time.Location, error) {
+	name := field
+	if name == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(TimeZoneMetadataKey); len(values) > 0 {
+				name = values[0]
+			}
+		}
+	}
+
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+
+	return loc, nil
+}
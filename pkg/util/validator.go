@@ -1,6 +1,16 @@
 package util
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	todo "github.com/ariefro/simple-to-do-service/api/protogen/todos"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
 
 func ValidateTitle(value string) error {
 	if value == "" {
@@ -9,3 +19,123 @@ func ValidateTitle(value string) error {
 
 	return nil
 }
+
+func ValidatePriority(value todo.Priority) error {
+	if _, ok := todo.Priority_name[int32(value)]; !ok {
+		return fmt.Errorf("priority must be between P1 and P4")
+	}
+
+	return nil
+}
+
+// ValidateDueAt checks an optional due date. A date-only due date must be
+// given as midnight UTC so it compares the same way for every caller.
+func ValidateDueAt(value *timestamppb.Timestamp, dateOnly bool) error {
+	if value == nil {
+		if dateOnly {
+			return fmt.Errorf("due_date_only requires due_at")
+		}
+		return nil
+	}
+
+	if err := value.CheckValid(); err != nil {
+		return fmt.Errorf("invalid due_at: %v", err)
+	}
+
+	if dateOnly && !value.AsTime().Equal(value.AsTime().Truncate(24*time.Hour)) {
+		return fmt.Errorf("date-only due_at must be midnight UTC")
+	}
+
+	return nil
+}
+
+func ValidateReminderBeforeDue(value *durationpb.Duration, dueAt *timestamppb.Timestamp) error {
+	if value == nil {
+		return nil
+	}
+
+	if dueAt == nil {
+		return fmt.Errorf("reminder_before_due requires due_at")
+	}
+
+	if err := value.CheckValid(); err != nil {
+		return fmt.Errorf("invalid reminder_before_due: %v", err)
+	}
+
+	if value.AsDuration() < 0 {
+		return fmt.Errorf("reminder_before_due cannot be negative")
+	}
+
+	return nil
+}
+
+func ValidateChecklistText(value string) error {
+	if value == "" {
+		return fmt.Errorf("checklist item text cannot be empty")
+	}
+
+	if utf8.RuneCountInString(value) > 500 {
+		return fmt.Errorf("checklist item text cannot be longer than 500 characters")
+	}
+
+	return nil
+}
+
+// ValidateLabel checks a label of a todo. Labels are stored joined by
+// commas and written as single words in queries, so they cannot contain
+// either.
+func ValidateLabel(value string) error {
+	if value == "" {
+		return fmt.Errorf("label cannot be empty")
+	}
+
+	if utf8.RuneCountInString(value) > 64 {
+		return fmt.Errorf("label cannot be longer than 64 characters")
+	}
+
+	if strings.ContainsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		return fmt.Errorf("label cannot contain spaces or commas")
+	}
+
+	return nil
+}
+
+func ValidateCommentBody(value string) error {
+	if value == "" {
+		return fmt.Errorf("comment cannot be empty")
+	}
+
+	if utf8.RuneCountInString(value) > 10000 {
+		return fmt.Errorf("comment cannot be longer than 10000 characters")
+	}
+
+	return nil
+}
+
+func ValidateFilename(value string) error {
+	if value == "" {
+		return fmt.Errorf("filename cannot be empty")
+	}
+
+	if utf8.RuneCountInString(value) > 255 {
+		return fmt.Errorf("filename cannot be longer than 255 characters")
+	}
+
+	if strings.ContainsAny(value, "/\\\x00") {
+		return fmt.Errorf("filename cannot contain path separators")
+	}
+
+	return nil
+}
+
+func ValidateViewName(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("view name cannot be empty")
+	}
+
+	if utf8.RuneCountInString(value) > 100 {
+		return fmt.Errorf("view name cannot be longer than 100 characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package util_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ariefro/simple-to-do-service/pkg/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateChecklistTextCountsCharacters(t *testing.T) {
+	assert.NoError(t, util.ValidateChecklistText(strings.Repeat("é", 500)))
+	assert.Error(t, util.ValidateChecklistText(strings.Repeat("é", 501)))
+	assert.Error(t, util.ValidateChecklistText(""))
+}
+
+func TestValidateLabel(t *testing.T) {
+	assert.NoError(t, util.ValidateLabel("work"))
+	assert.NoError(t, util.ValidateLabel(strings.Repeat("仕", 64)))
+	assert.Error(t, util.ValidateLabel(strings.Repeat("仕", 65)))
+	assert.Error(t, util.ValidateLabel("deep work"))
+	assert.Error(t, util.ValidateLabel("a,b"))
+	assert.Error(t, util.ValidateLabel(""))
+}
+
+func TestValidateCommentBodyCountsCharacters(t *testing.T) {
+	assert.NoError(t, util.ValidateCommentBody(strings.Repeat("日", 10000)))
+	assert.Error(t, util.ValidateCommentBody(strings.Repeat("日", 10001)))
+	assert.Error(t, util.ValidateCommentBody(""))
+}
+
+func TestValidateFilenameCountsCharacters(t *testing.T) {
+	assert.NoError(t, util.ValidateFilename(strings.Repeat("ф", 251)+".pdf"))
+	assert.Error(t, util.ValidateFilename(strings.Repeat("ф", 252)+".pdf"))
+	assert.Error(t, util.ValidateFilename("a/b.pdf"))
+}
+
+func TestValidateViewNameCountsCharacters(t *testing.T) {
+	assert.NoError(t, util.ValidateViewName(strings.Repeat("週", 60)))
+	assert.NoError(t, util.ValidateViewName(strings.Repeat("週", 100)))
+	assert.Error(t, util.ValidateViewName(strings.Repeat("週", 101)))
+	assert.Error(t, util.ValidateViewName("  "))
+}